@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// analyzeSpeciesCommand reports, for each species, which genotypes can be
+// identified by color alone, and which test crosses with seed flowers
+// distinguish the genotypes of ambiguous colors.
+func analyzeSpeciesCommand(args []string) error {
+	fs := flag.NewFlagSet("analyze-species", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s analyze-species [species...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	species := flower.AllSpecies()
+	if fs.NArg() > 0 {
+		species = nil
+		for _, name := range fs.Args() {
+			s, ok := flower.SpeciesByName(name)
+			if !ok {
+				return fmt.Errorf("unknown species %q", name)
+			}
+			species = append(species, s)
+		}
+	}
+
+	for i, s := range species {
+		if i > 0 {
+			fmt.Println()
+		}
+		printPhenotypeAnalysis(s)
+	}
+	return nil
+}
+
+func printPhenotypeAnalysis(s flower.Species) {
+	seedName := map[flower.Genotype]string{}
+	for _, g := range s.Seeds() {
+		seedName[g] = fmt.Sprintf("Seed %s (%s)", s.RenderPhenotype(s.Phenotype(g)), s.RenderGenotype(g))
+	}
+	renderGenotypes := func(gs []flower.Genotype) string {
+		strs := make([]string, len(gs))
+		for i, g := range gs {
+			strs[i] = s.RenderGenotype(g)
+		}
+		return strings.Join(strs, ", ")
+	}
+
+	fmt.Printf("%s:\n", s.Name())
+	for _, pg := range s.PhenotypeGroups() {
+		if pg.Unique() {
+			fmt.Printf("  %s: %s (identifiable by color)\n", s.RenderPhenotype(pg.Phenotype), s.RenderGenotype(pg.Genotypes[0]))
+			continue
+		}
+		fmt.Printf("  %s: %d genotypes (%s)\n", s.RenderPhenotype(pg.Phenotype), len(pg.Genotypes), renderGenotypes(pg.Genotypes))
+
+		crosses, indistinguishable := s.DistinguishingTestCrosses(pg.Genotypes, s.Seeds())
+		if len(crosses) > 0 {
+			names := make([]string, len(crosses))
+			for i, g := range crosses {
+				names[i] = seedName[g]
+			}
+			fmt.Printf("    Test crosses: %s\n", strings.Join(names, ", "))
+		}
+		for _, pair := range indistinguishable {
+			fmt.Printf("    Indistinguishable by seed test crosses: %s\n", renderGenotypes(pair[:]))
+		}
+	}
+}
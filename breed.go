@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// breedCommand breeds two flowers, printing the possible children.
+func breedCommand(args []string) error {
+	fs := flag.NewFlagSet("breed", flag.ExitOnError)
+	speciesName := fs.String("species", "Roses", "The species of flower to breed.")
+	jsonOut := fs.Bool("json", false, "If set, print the result as JSON.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s breed [flags] <genetic distribution> <genetic distribution>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, ok := flower.SpeciesByName(*speciesName)
+	if !ok {
+		return fmt.Errorf("unknown species %q", *speciesName)
+	}
+	var parents [2]flower.GeneticDistribution
+	for i := range parents {
+		gd, err := s.ParseGeneticDistribution(fs.Arg(i))
+		if err != nil {
+			return fmt.Errorf("couldn't parse parent: %v", err)
+		}
+		parents[i] = gd
+	}
+	child := parents[0].Breed(parents[1])
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newJSONBreeding(s, parents, child))
+	}
+	fmt.Printf("Breeding %s with %s:\n\n", s.RenderGeneticDistribution(parents[0]), s.RenderGeneticDistribution(parents[1]))
+	return printDistribution(os.Stdout, s, child)
+}
+
+// jsonBreeding is the JSON representation of the result of the breed command.
+type jsonBreeding struct {
+	Species    string           `json:"species"`
+	Parents    [2]string        `json:"parents"`
+	Children   []jsonChild      `json:"children"`
+	Phenotypes []jsonPhenotypes `json:"phenotypes"`
+}
+
+type jsonChild struct {
+	Genotype    string  `json:"genotype"`
+	Probability float64 `json:"probability"`
+	Phenotype   string  `json:"phenotype"`
+}
+
+type jsonPhenotypes struct {
+	Phenotype   string  `json:"phenotype"`
+	Probability float64 `json:"probability"`
+}
+
+func newJSONBreeding(s flower.Species, parents [2]flower.GeneticDistribution, child flower.GeneticDistribution) jsonBreeding {
+	rslt := jsonBreeding{
+		Species:    s.Name(),
+		Parents:    [2]string{s.RenderGeneticDistribution(parents[0]), s.RenderGeneticDistribution(parents[1])},
+		Children:   []jsonChild{},
+		Phenotypes: []jsonPhenotypes{},
+	}
+
+	var total uint64
+	child.Visit(func(_ flower.Genotype, odds uint64) bool {
+		total += odds
+		return true
+	})
+	child.Visit(func(g flower.Genotype, odds uint64) bool {
+		rslt.Children = append(rslt.Children, jsonChild{
+			Genotype:    s.RenderGenotype(g),
+			Probability: float64(odds) / float64(total),
+			Phenotype:   s.RenderPhenotype(s.Phenotype(g)),
+		})
+		return true
+	})
+
+	pd := s.PhenotypeDistribution(child)
+	var ps []flower.Phenotype
+	for p := range pd {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	for _, p := range ps {
+		rslt.Phenotypes = append(rslt.Phenotypes, jsonPhenotypes{s.RenderPhenotype(p), float64(pd[p]) / float64(total)})
+	}
+	return rslt
+}
@@ -3,24 +3,46 @@ package breedgraph
 import (
 	"fmt"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
-	"sync/atomic"
 
 	"github.com/BranLwyd/acnh_flowers/flower"
 )
 
 type Graph struct {
-	tests []*Test
+	tests  []*Test
+	policy *Policy
+	equiv  *Equivalence
 
 	verts        []*vertex
-	vertMap      map[flower.GeneticDistribution]*vertex
+	vertIndex    vertexIndex
 	vertFrontier int
+	breedings    int // the number of pairs of vertices bred so far
 }
 
 type vertex struct {
-	gd   flower.GeneticDistribution
-	pred *edge
+	id        int     // the index of this vertex in its graph's verts
+	dist      distKey // the distribution of this vertex, as first found; this is the distribution with which it is bred
+	canonical distKey // the canonical distribution of this vertex, under its graph's equivalence
+	next      *vertex // the next vertex in this vertex's vertIndex chain
+	pred      *edge
+
+	// Set if pred produces a distribution other than dist (though equivalent
+	// to it) from its parents' distributions. The distribution of this vertex
+	// along the path through pred is then given by pathValue.
+	inexact bool
+
+	// Vertices whose best predecessor has this vertex as a parent. Used to
+	// invalidate cached information about the path to each vertex when the
+	// path to one of its ancestors changes.
+	children []*vertex
+
+	// Cached information about the path to this vertex, valid only if cached
+	// is set. A vertex is cached only if its ancestors are.
+	cached    bool
+	ancestors []*vertex // all vertices on the path to this vertex, including itself, sorted by id
+	stats     PathStats
 }
 
 type edge struct {
@@ -32,26 +54,72 @@ type edge struct {
 }
 
 func NewGraph(tests []*Test, initialFlowers []flower.GeneticDistribution) *Graph {
-	verts := make([]*vertex, len(initialFlowers))
-	vertMap := map[flower.GeneticDistribution]*vertex{}
-	for i, gd := range initialFlowers {
-		v := &vertex{gd, nil}
-		verts[i] = v
-		vertMap[gd] = v
+	g := &Graph{
+		tests:     tests,
+		policy:    MinCostPolicy,
+		equiv:     IdentityEquivalence,
+		vertIndex: vertexIndex{},
 	}
-	return &Graph{
-		tests:   tests,
-		verts:   verts,
-		vertMap: vertMap,
+	for _, gd := range initialFlowers {
+		k := newDistKey(gd.ToSparse())
+		g.addVertex(k, k)
 	}
+	return g
+}
+
+// addVertex adds a new vertex with the given distribution to the graph. If a
+// vertex with the same canonical distribution already exists, the new vertex
+// is not indexed.
+func (g *Graph) addVertex(dist, canonical distKey) *vertex {
+	v := &vertex{id: len(g.verts), dist: dist, canonical: canonical}
+	g.verts = append(g.verts, v)
+	if g.vertIndex.get(canonical) == nil {
+		g.vertIndex.put(v)
+	}
+	return v
+}
+
+// truncate removes all but the first n vertices. The removed vertices must not
+// be the parents of any remaining vertex's best predecessor.
+func (g *Graph) truncate(n int) {
+	for i, v := range g.verts[n:] {
+		g.vertIndex.remove(v)
+		if e := v.pred; e != nil {
+			e.pred[0].removeChild(v)
+			e.pred[1].removeChild(v)
+		}
+		g.verts[n+i] = nil
+	}
+	g.verts = g.verts[:n]
+}
+
+// distributions returns the distributions of the first n vertices, indexed by
+// vertex ID.
+func (g *Graph) distributions(n int) []flower.SparseGeneticDistribution {
+	rslt := make([]flower.SparseGeneticDistribution, n)
+	for i, v := range g.verts[:n] {
+		rslt[i] = v.dist.sparse()
+	}
+	return rslt
+}
+
+// keys returns the key & canonical key of gd, whose dense form is dense.
+func (g *Graph) keys(gd flower.SparseGeneticDistribution, dense flower.GeneticDistribution) (dist, canonical distKey) {
+	dist = newDistKey(gd)
+	if g.equiv == IdentityEquivalence {
+		return dist, dist
+	}
+	return dist, newDistKey(g.equiv.canonical(dense).ToSparse())
 }
 
 func (g *Graph) Search(pred func(flower.GeneticDistribution) bool) (_ Vertex, ok bool) {
 	var rslt *vertex
+	var rsltStats PathStats
+	var gd flower.GeneticDistribution
 	for _, v := range g.verts {
-		if pred(v.gd) {
-			if rslt == nil || v.pathCost() < rslt.pathCost() {
-				rslt = v
+		if v.dist.denseInto(&gd); pred(gd) {
+			if stats := v.pathStats(); rslt == nil || g.policy.less(stats, rsltStats) {
+				rslt, rsltStats = v, stats
 			}
 		}
 	}
@@ -60,76 +128,237 @@ func (g *Graph) Search(pred func(flower.GeneticDistribution) bool) (_ Vertex, ok
 
 func (g *Graph) Expand(keepPred func(flower.GeneticDistribution) bool) {
 	initialVertCnt := len(g.verts)
-
-	type result struct {
-		e    *edge
-		gd   flower.GeneticDistribution
-		keep bool
-	}
-	rsltsCh := make(chan []result)
-	rsltsPool := &sync.Pool{New: func() interface{} { return []result(nil) }}
-
-	// Spawn workers.
-	totalWorkerCnt := runtime.GOMAXPROCS(0)
-	workerCnt := int64(totalWorkerCnt)
-	for i := 0; i < totalWorkerCnt; i++ {
-		go func(base int) {
-			defer func() {
-				if atomic.AddInt64(&workerCnt, -1) == 0 {
-					close(rsltsCh)
-				}
-			}()
-
-			for i := base; i < initialVertCnt; i += totalWorkerCnt {
-				rslts := rsltsPool.Get().([]result)
-				va := g.verts[i]
-				minJ := g.vertFrontier
-				if i > minJ {
-					minJ = i
-				}
-				for _, vb := range g.verts[minJ:initialVertCnt] {
-					gd := va.gd.Breed(vb.gd)
-					for _, test := range g.tests {
-						gd, cost := test.Test(gd)
-						if gd.IsZero() {
-							// Test can't be applied to this distribution.
-							continue
-						}
-						e := &edge{pred: [2]*vertex{va, vb}, test: test, cost: cost}
-						rslts = append(rslts, result{e, gd, keepPred(gd)})
-					}
-				}
+	verts, gds := g.verts[:initialVertCnt], g.distributions(initialVertCnt)
+	rsltsPool := &sync.Pool{New: func() interface{} { return []expandResult(nil) }}
+
+	// Spawn workers. Worker k handles every vertex i with i % workerCnt == k, in
+	// increasing order, sending its results on rsltsChs[k].
+	workerCnt := runtime.GOMAXPROCS(0)
+	rsltsChs := make([]chan []expandResult, workerCnt)
+	for k := range rsltsChs {
+		rsltsChs[k] = make(chan []expandResult, 1)
+		go func(base int, rsltsCh chan<- []expandResult) {
+			defer close(rsltsCh)
+			for i := base; i < initialVertCnt; i += workerCnt {
+				rslts := rsltsPool.Get().([]expandResult)
+				breedRow(g.tests, gds, i, g.minJ(i), func(j, test int, gd flower.SparseGeneticDistribution, cost float64) {
+					rslts = append(rslts, g.result(verts, i, j, test, gd, cost, keepPred))
+				})
 				rsltsCh <- rslts
 			}
-		}(i)
+		}(k, rsltsChs[k])
 	}
 
-	// Handle results.
-	for rslts := range rsltsCh {
-		for _, rslt := range rslts {
-			e, gd, keep := rslt.e, rslt.gd, rslt.keep
-			if v, ok := g.vertMap[gd]; ok {
-				// This vertex already exists. Update lowest-cost if necessary.
-				oldPathCost, newPathCost := v.pathCost(), e.pathCost()
-				if newPathCost < oldPathCost || (newPathCost == oldPathCost && e.test.Priority() < v.pred.test.Priority()) {
-					e.succ, v.pred = v, e
-				}
+	g.merge(rsltsChs, gds, func(rslts []expandResult) { rsltsPool.Put(rslts[:0]) })
+}
+
+// expandResult is the result of breeding a pair of vertices & applying a test
+// to their children, while expanding a graph.
+type expandResult struct {
+	e               *edge
+	dist, canonical distKey
+	keep            bool
+}
+
+// minJ returns the first vertex which vertex i must be bred with while
+// expanding the graph: pairs of vertices which were both present at the last
+// expansion have already been bred.
+func (g *Graph) minJ(i int) int {
+	if i > g.vertFrontier {
+		return i
+	}
+	return g.vertFrontier
+}
+
+// breedRow breeds the flower with distribution gds[i] with each flower j in
+// [minJ, len(gds)), applying each test to the children. f is called with each
+// result to which a test can be applied, in order of j & then test.
+func breedRow(tests []*Test, gds []flower.SparseGeneticDistribution, i, minJ int, f func(j, test int, gd flower.SparseGeneticDistribution, cost float64)) {
+	for j := minJ; j < len(gds); j++ {
+		parents := [2]flower.SparseGeneticDistribution{gds[i], gds[j]}
+		gd := gds[i].Breed(gds[j])
+		for t, test := range tests {
+			gd, cost := test.testBred(parents, gd)
+			if gd.IsZero() {
+				// Test can't be applied to this distribution.
 				continue
 			}
+			f(j, t, gd, cost)
+		}
+	}
+}
+
+// result creates the result of breeding verts[i] & verts[j] & applying the
+// given test, producing gd at the given cost.
+func (g *Graph) result(verts []*vertex, i, j, test int, gd flower.SparseGeneticDistribution, cost float64, keepPred func(flower.GeneticDistribution) bool) expandResult {
+	e := &edge{pred: [2]*vertex{verts[i], verts[j]}, test: g.tests[test], cost: cost}
+	dense := gd.ToGeneticDistribution()
+	dist, canonical := g.keys(gd, dense)
+	return expandResult{e, dist, canonical, keepPred(dense)}
+}
+
+// merge adds the results of an expansion to the graph. Results for each vertex
+// i among the first len(gds) are received from rsltsChs[i % len(rsltsChs)];
+// release is called with each set of results once they have been handled. If
+// any channel is closed before all of its results are received, the graph is
+// left partially expanded & merge returns false.
+func (g *Graph) merge(rsltsChs []chan []expandResult, gds []flower.SparseGeneticDistribution, release func([]expandResult)) (ok bool) {
+	// Results are handled in order of i (and, for each i, in order of j & then
+	// test), regardless of the number of workers, so that the resulting graph
+	// is deterministic: new vertices are appended in a fixed order, & ties
+	// between equally-good edges go to the first one handled.
+	var improved []improvement
+	for i := range gds {
+		rslts, ok := <-rsltsChs[i%len(rsltsChs)]
+		if !ok {
+			g.relax(improved, gds)
+			return false
+		}
+		g.breedings += len(gds) - g.minJ(i)
+		for _, rslt := range rslts {
+			if v := g.add(rslt.e, rslt.dist, rslt.canonical, rslt.keep); v != nil {
+				improved = append(improved, improvement{v, i})
+			}
+		}
+		release(rslts)
+	}
+	g.relax(improved, gds)
+	g.vertFrontier = len(gds)
+	return true
+}
+
+// add adds the result of edge e, with distribution dist, to the graph. If a
+// vertex with the same canonical distribution already exists, e becomes its
+// best predecessor if e improves on its current best predecessor; in that case,
+// the vertex is returned. Otherwise, a new vertex is created if keep is set.
+func (g *Graph) add(e *edge, dist, canonical distKey, keep bool) (improved *vertex) {
+	if v := g.vertIndex.get(canonical); v != nil {
+		// This vertex already exists. Update best predecessor if necessary.
+		if g.policy.less(e.pathStats(), v.pathStats()) && !e.dependsOn(v) {
+			// v may already have been bred, so its distribution must not
+			// change; the path to it records the distribution it produces.
+			v.inexact = dist.enc != v.dist.enc
+			v.setPred(e)
+			return v
+		}
+		return nil
+	}
 
-			// This vertex does not yet exist in the graph. Create a new vertex, as long as the caller wants to keep it.
-			if !keep {
-				// Caller does not want us to keep this result.
+	// This vertex does not yet exist in the graph. Create a new vertex, as long as the caller wants to keep it.
+	if !keep {
+		// Caller does not want us to keep this result.
+		return nil
+	}
+	g.addVertex(dist, canonical).setPred(e)
+	return nil
+}
+
+// improvement records that the path to a vertex improved while merging the
+// results for the given row of an expansion.
+type improvement struct {
+	v   *vertex
+	row int
+}
+
+// relax ensures that each vertex's best predecessor remains the best available
+// after the paths to the given vertices have improved. Any breeding using an
+// improved vertex, or one of its descendants, may have been rejected when the
+// vertex's path was worse; so each such vertex among the first n vertices is
+// bred again with those of the first n vertices (all of whose pairs have been
+// bred by Expand) with which it was bred before the improvement, improving the
+// predecessors of existing vertices where possible. Since those improvements
+// may in turn enable others, this is repeated until no further improvement is
+// possible. gds holds the distributions of the first n vertices.
+//
+// Path stats aren't additive (ancestors shared by both parents are counted
+// once), so improving one vertex can worsen its descendants. A vertex is
+// therefore bred again only if its path has strictly improved since it was last
+// bred. Since the graph has finitely many paths, & the policy orders paths
+// strictly, this bounds the number of times each vertex is bred.
+func (g *Graph) relax(improved []improvement, gds []flower.SparseGeneticDistribution) {
+	n := len(gds)
+
+	// stale determines if the pair of vertices with the given IDs was bred
+	// before an improvement made while merging the given row. Pairs among the
+	// first vertFrontier vertices were bred by earlier expansions; others were
+	// bred while merging the row of the lower ID.
+	stale := func(a, b, row int) bool {
+		if a > b {
+			a, b = b, a
+		}
+		return a <= row || b < g.minJ(a)
+	}
+
+	// Vertices to be bred again are queued in work, along with the latest
+	// row whose pairs must be bred again.
+	var work []*vertex
+	rows := map[*vertex]int{}
+	var push func(*vertex, int)
+	push = func(v *vertex, row int) {
+		if r, ok := rows[v]; ok {
+			if r >= row {
+				return
+			}
+		} else {
+			work = append(work, v)
+		}
+		rows[v] = row
+		for _, c := range v.children {
+			push(c, row)
+		}
+	}
+	for _, imp := range improved {
+		push(imp.v, imp.row)
+	}
+
+	bred := map[*vertex]PathStats{} // the stats of each vertex when it was last bred
+	for len(work) > 0 {
+		v := work[0]
+		work = work[1:]
+		row := rows[v]
+		delete(rows, v)
+		if v.id >= n {
+			// This vertex is new, so it has not yet been bred.
+			continue
+		}
+		stats := v.pathStats()
+		if g.policy.less(stats, stats) {
+			panic(fmt.Sprintf("policy %q is not a strict order: a path is better than itself", g.policy.name))
+		}
+		if last, ok := bred[v]; ok && !g.policy.less(stats, last) {
+			continue
+		}
+		bred[v] = stats
+
+		for _, u := range g.verts[:n] {
+			if !stale(u.id, v.id, row) {
 				continue
 			}
-			v := &vertex{gd: gd, pred: e}
-			e.succ, v.pred = v, e
-			g.verts = append(g.verts, v)
-			g.vertMap[gd] = v
+			if r, ok := rows[u]; ok && stale(u.id, v.id, r) {
+				if _, ok := bred[u]; !ok {
+					// u will be bred with v later.
+					continue
+				}
+			}
+			va, vb := u, v
+			if v.id < u.id {
+				va, vb = v, u
+			}
+			parents := [2]flower.SparseGeneticDistribution{gds[va.id], gds[vb.id]}
+			gd := parents[0].Breed(parents[1])
+			g.breedings++
+			for _, test := range g.tests {
+				gd, cost := test.testBred(parents, gd)
+				if gd.IsZero() {
+					continue
+				}
+				dist, canonical := g.keys(gd, gd.ToGeneticDistribution())
+				if w := g.add(&edge{pred: [2]*vertex{va, vb}, test: test, cost: cost}, dist, canonical, false); w != nil {
+					push(w, n)
+				}
+			}
 		}
-		rsltsPool.Put(rslts[:0])
 	}
-	g.vertFrontier = initialVertCnt
 }
 
 func (g *Graph) VisitVertices(f func(Vertex)) {
@@ -150,29 +379,142 @@ func (g *Graph) VisitEdges(f func(Edge)) {
 	})
 }
 
-func (e *edge) pathCost() float64 {
-	var cost float64
-	e.visitPath(func(x interface{}) {
-		if e, ok := x.(*edge); ok {
-			cost += e.cost
+func (e *edge) pathCost() float64 { return e.pathStats().Cost }
+
+func (v *vertex) pathCost() float64 { return v.pathStats().Cost }
+
+// dependsOn determines if v is on the path to either of e's parents, in which
+// case making e the best predecessor of v would create a cycle.
+func (e *edge) dependsOn(v *vertex) bool {
+	for _, p := range e.pred {
+		p.cache()
+		i := sort.Search(len(p.ancestors), func(i int) bool { return p.ancestors[i].id >= v.id })
+		if i < len(p.ancestors) && p.ancestors[i] == v {
+			return true
 		}
-	})
-	return cost
+	}
+	return false
 }
 
-func (e *edge) visitPath(f func(interface{})) {
-	visitSubgraphPathingToAllOf([]interface{}{e}, f)
+// setPred sets the best predecessor of v to e, invalidating cached path
+// information for v & its descendants.
+func (v *vertex) setPred(e *edge) {
+	if old := v.pred; old != nil {
+		old.pred[0].removeChild(v)
+		old.pred[1].removeChild(v)
+	}
+	e.succ, v.pred = v, e
+	e.pred[0].children = append(e.pred[0].children, v)
+	if e.pred[1] != e.pred[0] {
+		e.pred[1].children = append(e.pred[1].children, v)
+	}
+	v.invalidate()
+}
+
+func (v *vertex) removeChild(c *vertex) {
+	for i, x := range v.children {
+		if x == c {
+			last := len(v.children) - 1
+			v.children[i], v.children[last] = v.children[last], nil
+			v.children = v.children[:last]
+			return
+		}
+	}
+}
+
+// invalidate discards cached path information for v & its descendants.
+func (v *vertex) invalidate() {
+	if !v.cached {
+		// Descendants of an uncached vertex are never cached.
+		return
+	}
+	v.cached, v.ancestors = false, nil
+	for _, c := range v.children {
+		c.invalidate()
+	}
 }
 
-func (v *vertex) pathCost() float64 {
+// cache ensures that cached path information for v is valid.
+func (v *vertex) cache() {
+	if v.cached {
+		return
+	}
 	if v.pred == nil {
-		return 0
+		v.cached, v.ancestors, v.stats = true, []*vertex{v}, PathStats{Flowers: 1}
+		return
 	}
-	return v.pred.pathCost()
+
+	p0, p1 := v.pred.pred[0], v.pred.pred[1]
+	p0.cache()
+	p1.cache()
+	ancestors := make([]*vertex, 0, len(p0.ancestors)+len(p1.ancestors)+1)
+	unionAncestors(p0.ancestors, p1.ancestors, func(a *vertex) { ancestors = append(ancestors, a) })
+	i := sort.Search(len(ancestors), func(i int) bool { return ancestors[i].id >= v.id })
+	ancestors = append(ancestors, nil)
+	copy(ancestors[i+1:], ancestors[i:])
+	ancestors[i] = v
+
+	v.stats = v.pred.pathStats()
+	v.cached, v.ancestors = true, ancestors
 }
 
-func (v *vertex) visitPath(f func(interface{})) {
-	visitSubgraphPathingToAllOf([]interface{}{v}, f)
+// pathValue returns the distribution of v along the path to it: that produced
+// by breeding the path values of its best predecessor's parents & applying the
+// predecessor's test. This is the same as v's distribution, unless the path
+// passes through an inexact vertex.
+func (v *vertex) pathValue() flower.SparseGeneticDistribution {
+	v.cache()
+	exact := true
+	for _, a := range v.ancestors {
+		exact = exact && !a.inexact
+	}
+	if exact {
+		return v.dist.sparse()
+	}
+
+	values := map[*vertex]flower.SparseGeneticDistribution{}
+	var value func(*vertex) flower.SparseGeneticDistribution
+	value = func(v *vertex) flower.SparseGeneticDistribution {
+		if gd, ok := values[v]; ok {
+			return gd
+		}
+		gd := v.dist.sparse()
+		if v.pred != nil {
+			// The graph's tests respect its equivalence, so they accept
+			// any flower equivalent to the one bred.
+			parents := [2]flower.SparseGeneticDistribution{value(v.pred.pred[0]), value(v.pred.pred[1])}
+			if gd, _ = v.pred.test.testBred(parents, parents[0].Breed(parents[1])); gd.IsZero() {
+				panic(fmt.Sprintf("test %q rejects the flower bred along the path to vertex %d", v.pred.test.Name(), v.id))
+			}
+		}
+		values[v] = gd
+		return gd
+	}
+	return value(v)
+}
+
+// unionAncestors calls f on each vertex in either of the given sorted slices of
+// vertices, in order of id. Vertices in both slices are visited only once.
+func unionAncestors(a, b []*vertex, f func(*vertex)) {
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0].id < b[0].id:
+			f(a[0])
+			a = a[1:]
+		case a[0].id > b[0].id:
+			f(b[0])
+			b = b[1:]
+		default:
+			f(a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+	for _, x := range a {
+		f(x)
+	}
+	for _, x := range b {
+		f(x)
+	}
 }
 
 // vertsAndEdges is MODIFIED & CONSUMED by this function.
@@ -204,18 +546,59 @@ func visitSubgraphPathingToAllOf(vertsAndEdges []interface{}, f func(interface{}
 type Test struct {
 	name     string
 	priority int
-	test     func(flower.GeneticDistribution) (_ flower.GeneticDistribution, cost float64)
+
+	// keep determines which genotypes of a child of the given parents pass the
+	// test, or returns nil if the test can't be applied to children of those
+	// parents. Parents are zero if unknown.
+	keep func(parents [2]flower.SparseGeneticDistribution) func(flower.Genotype) bool
+
+	// spec describes how the test was constructed.
+	spec testSpec
 }
 
 func (t *Test) Name() string  { return t.name }
 func (t *Test) Priority() int { return t.priority }
 
+// Test applies the test to a distribution whose parents are unknown. See
+// TestBred.
 func (t *Test) Test(gd flower.GeneticDistribution) (_ flower.GeneticDistribution, cost float64) {
-	return t.test(gd)
+	return t.TestBred([2]flower.GeneticDistribution{}, gd)
+}
+
+// TestBred applies the test to gd, the distribution of children of the given
+// parents. It returns the distribution of children passing the test, along with
+// the expected number of children needed to get one that passes. If the test
+// can't be applied or no child can pass it, the zero distribution is returned.
+func (t *Test) TestBred(parents [2]flower.GeneticDistribution, gd flower.GeneticDistribution) (_ flower.GeneticDistribution, cost float64) {
+	rslt, cost := t.testBred([2]flower.SparseGeneticDistribution{parents[0].ToSparse(), parents[1].ToSparse()}, gd.ToSparse())
+	return rslt.ToGeneticDistribution(), cost
+}
+
+// testBred is TestBred, for sparse distributions.
+func (t *Test) testBred(parents [2]flower.SparseGeneticDistribution, gd flower.SparseGeneticDistribution) (_ flower.SparseGeneticDistribution, cost float64) {
+	keep := t.keep(parents)
+	if keep == nil {
+		return flower.SparseGeneticDistribution{}, 0
+	}
+	var succChances, totalChances uint64
+	gd.Visit(func(g flower.Genotype, p uint64) bool {
+		totalChances += p
+		if keep(g) {
+			succChances += p
+		}
+		return true
+	})
+	if succChances == 0 {
+		// This test can't be applied.
+		return flower.SparseGeneticDistribution{}, 0
+	}
+	return gd.Filter(keep), float64(totalChances) / float64(succChances)
 }
 
 var (
-	NoTest *Test = &Test{"", 0, func(gd flower.GeneticDistribution) (flower.GeneticDistribution, float64) { return gd, 1 }}
+	NoTest *Test = &Test{"", 0, func([2]flower.SparseGeneticDistribution) func(flower.Genotype) bool {
+		return func(flower.Genotype) bool { return true }
+	}, testSpec{Op: "none"}}
 )
 
 func PhenotypeTest(s flower.Species, phenotypes ...flower.Phenotype) *Test {
@@ -235,32 +618,144 @@ func PhenotypeTest(s flower.Species, phenotypes ...flower.Phenotype) *Test {
 		if written {
 			nameSB.WriteString(",")
 		}
-		nameSB.WriteString(p.String())
+		nameSB.WriteString(s.RenderPhenotype(p))
 		written = true
 	}
 	nameSB.WriteString("}")
 	name := nameSB.String()
 
 	priority := len(phenotypes)
-	return &Test{name, priority, func(gd flower.GeneticDistribution) (flower.GeneticDistribution, float64) {
-		var succChances, totalChances uint64
-		rslt := gd.Update(func(mgd *flower.MutableGeneticDistribution) {
-			gd.Visit(func(g flower.Genotype, p uint64) bool {
-				totalChances += p
-				if validPhenotype(s.Phenotype(g)) {
-					succChances += p
-				} else {
-					mgd.SetOdds(g, 0)
+	var kept [256]bool
+	for _, g := range s.Genotypes() {
+		kept[g] = validPhenotype(s.Phenotype(g))
+	}
+	keep := func(g flower.Genotype) bool { return kept[g] }
+	spec := testSpec{Op: "phenotype", Species: s.Name(), species: s}
+	for _, p := range phenotypes {
+		spec.Phenotypes = append(spec.Phenotypes, s.RenderPhenotype(p))
+	}
+	return &Test{name, priority, func([2]flower.SparseGeneticDistribution) func(flower.Genotype) bool { return keep }, spec}
+}
+
+// MatchesParentTest keeps only children with the same phenotype as one of their
+// parents: the first parent if parent is 0, or the second if it is 1. It can be
+// applied only if that parent's phenotype is known, i.e. all of its possible
+// genotypes share a phenotype. It panics if parent is neither 0 nor 1.
+func MatchesParentTest(s flower.Species, parent int) *Test {
+	if parent != 0 && parent != 1 {
+		panic(fmt.Sprintf("MatchesParentTest: invalid parent %d", parent))
+	}
+	name := fmt.Sprintf("P=Parent%d", parent+1)
+	return &Test{name, 1, func(parents [2]flower.SparseGeneticDistribution) func(flower.Genotype) bool {
+		p, ok := onlyPhenotype(s, parents[parent])
+		if !ok {
+			return nil
+		}
+		return func(g flower.Genotype) bool { return s.Phenotype(g) == p }
+	}, testSpec{Op: "matches-parent", Species: s.Name(), Parent: parent, species: s}}
+}
+
+// UnlikeParentsTest discards children with the same phenotype as either of
+// their parents. It can be applied only if both parents' phenotypes are known.
+func UnlikeParentsTest(s flower.Species) *Test {
+	return &Test{"P∉Parents", 2, func(parents [2]flower.SparseGeneticDistribution) func(flower.Genotype) bool {
+		p0, ok0 := onlyPhenotype(s, parents[0])
+		p1, ok1 := onlyPhenotype(s, parents[1])
+		if !ok0 || !ok1 {
+			return nil
+		}
+		return func(g flower.Genotype) bool { p := s.Phenotype(g); return p != p0 && p != p1 }
+	}, testSpec{Op: "unlike-parents", Species: s.Name(), species: s}}
+}
+
+// onlyPhenotype returns the phenotype shared by all possible genotypes of gd, or
+// ok = false if there is no such phenotype.
+func onlyPhenotype(s flower.Species, gd flower.SparseGeneticDistribution) (_ flower.Phenotype, ok bool) {
+	var rslt flower.Phenotype
+	gd.Visit(func(g flower.Genotype, _ uint64) bool {
+		p := s.Phenotype(g)
+		if ok && p != rslt {
+			ok = false
+			return false
+		}
+		rslt, ok = p, true
+		return true
+	})
+	return rslt, ok
+}
+
+// And keeps only children passing all of the given tests. It can be applied
+// only if all of the tests can be applied.
+func And(tests ...*Test) *Test {
+	return combine("and", " & ", tests, func(keeps []func(flower.Genotype) bool) func(flower.Genotype) bool {
+		return func(g flower.Genotype) bool {
+			for _, keep := range keeps {
+				if !keep(g) {
+					return false
 				}
-				return true
-			})
-		})
-		if succChances == 0 {
-			// This test can't be applied.
-			return flower.GeneticDistribution{}, 0
+			}
+			return true
+		}
+	})
+}
+
+// Or keeps children passing any of the given tests. It can be applied only if
+// all of the tests can be applied.
+func Or(tests ...*Test) *Test {
+	return combine("or", " | ", tests, func(keeps []func(flower.Genotype) bool) func(flower.Genotype) bool {
+		return func(g flower.Genotype) bool {
+			for _, keep := range keeps {
+				if keep(g) {
+					return true
+				}
+			}
+			return false
+		}
+	})
+}
+
+// Not keeps only children failing the given test. It can be applied only if
+// the given test can be applied.
+func Not(t *Test) *Test {
+	return &Test{"!" + parenthesize(t.name), t.priority + 1, func(parents [2]flower.SparseGeneticDistribution) func(flower.Genotype) bool {
+		keep := t.keep(parents)
+		if keep == nil {
+			return nil
+		}
+		return func(g flower.Genotype) bool { return !keep(g) }
+	}, testSpec{Op: "not", Tests: []testSpec{t.spec}}}
+}
+
+// combine creates a test combining the given tests' results with op, naming it
+// by joining the tests' names with sep. Its priority is the sum of the tests'
+// priorities. specOp names op in the test's spec.
+func combine(specOp, sep string, tests []*Test, op func([]func(flower.Genotype) bool) func(flower.Genotype) bool) *Test {
+	names := make([]string, len(tests))
+	priority := 0
+	spec := testSpec{Op: specOp, Tests: make([]testSpec, len(tests))}
+	for i, t := range tests {
+		names[i] = parenthesize(t.name)
+		priority += t.priority
+		spec.Tests[i] = t.spec
+	}
+	return &Test{strings.Join(names, sep), priority, func(parents [2]flower.SparseGeneticDistribution) func(flower.Genotype) bool {
+		keeps := make([]func(flower.Genotype) bool, len(tests))
+		for i, t := range tests {
+			if keeps[i] = t.keep(parents); keeps[i] == nil {
+				return nil
+			}
 		}
-		return rslt, float64(totalChances) / float64(succChances)
-	}}
+		return op(keeps)
+	}, spec}
+}
+
+// parenthesize wraps a test name in parentheses if it is made up of several
+// parts, so that it can be included in a larger name unambiguously.
+func parenthesize(name string) string {
+	if strings.Contains(name, " ") {
+		return "(" + name + ")"
+	}
+	return name
 }
 
 func PhenotypeTests(s flower.Species) []*Test {
@@ -329,28 +824,65 @@ func PhenotypeTestsUpToSize(s flower.Species, size int) []*Test {
 type Vertex struct{ v *vertex }
 
 func (v Vertex) IsZero() bool                       { return v.v == nil }
-func (v Vertex) Value() flower.GeneticDistribution  { return v.v.gd }
+func (v Vertex) Value() flower.GeneticDistribution  { return v.v.pathValue().ToGeneticDistribution() }
 func (v Vertex) BestPredecessor() (_ Edge, ok bool) { return Edge{v.v.pred}, v.v.pred != nil }
 func (v Vertex) PathCost() float64                  { return v.v.pathCost() }
+func (v Vertex) PathStats() PathStats               { return v.v.pathStats() }
 
+// VisitPathTo visits the vertices & edges of the lowest-cost path to v. All
+// vertices are visited before any edges; each is visited in the order given by
+// VisitPathInOrder.
 func (v Vertex) VisitPathTo(vertexVisitor func(Vertex), edgeVisitor func(Edge)) {
-	var verts []*vertex
-	var edges []*edge
+	var edges []Edge
+	v.VisitPathInOrder(func(v Vertex, _ int) {
+		vertexVisitor(v)
+	}, func(e Edge, _ int) {
+		edges = append(edges, e)
+	})
+	for _, e := range edges {
+		edgeVisitor(e)
+	}
+}
 
-	v.v.visitPath(func(x interface{}) {
-		switch x := x.(type) {
-		case *vertex:
-			verts = append(verts, x)
-		case *edge:
-			edges = append(edges, x)
+// VisitPathInOrder visits the vertices & edges of the lowest-cost path to v in
+// dependency order: each edge is visited after both of its parents, and each
+// vertex is visited immediately after the edge producing it. The visitors are
+// also passed the generation of each vertex or edge: vertices without a
+// predecessor are generation 0, and an edge & the vertex it produces are one
+// generation later than the later of the edge's parents.
+//
+// The order is deterministic: vertices are visited in order of generation, with
+// ties broken by the order in which a depth-first traversal from v (visiting
+// first parents before second parents) finishes with them.
+func (v Vertex) VisitPathInOrder(vertexVisitor func(_ Vertex, generation int), edgeVisitor func(_ Edge, generation int)) {
+	var verts []*vertex
+	gens := map[*vertex]int{}
+	var visit func(*vertex)
+	visit = func(v *vertex) {
+		if _, ok := gens[v]; ok {
+			return
 		}
-	})
+		gens[v] = 0
+		if v.pred != nil {
+			visit(v.pred.pred[0])
+			visit(v.pred.pred[1])
+			gen := gens[v.pred.pred[0]]
+			if g := gens[v.pred.pred[1]]; g > gen {
+				gen = g
+			}
+			gens[v] = gen + 1
+		}
+		verts = append(verts, v)
+	}
+	visit(v.v)
+	sort.SliceStable(verts, func(i, j int) bool { return gens[verts[i]] < gens[verts[j]] })
 
 	for _, v := range verts {
-		vertexVisitor(Vertex{v})
-	}
-	for _, e := range edges {
-		edgeVisitor(Edge{e})
+		gen := gens[v]
+		if v.pred != nil {
+			edgeVisitor(Edge{v.pred}, gen)
+		}
+		vertexVisitor(Vertex{v}, gen)
 	}
 }
 
@@ -0,0 +1,123 @@
+package breedgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// Equivalence determines which distributions a graph treats as the same
+// flower. Each distribution is mapped to a canonical distribution; all
+// distributions with the same canonical distribution share a single vertex.
+// The vertex is bred as the first of its distributions found, but its value is
+// the distribution produced along its best path.
+//
+// For the resulting graph to be meaningful, equivalent distributions must
+// remain equivalent when bred with the same flower & when filtered by any of
+// the graph's tests, & must agree on whatever predicate the graph is searched
+// for. The graph's tests must then filter equivalent distributions at the same
+// cost, so the costs along a path agree with its values; SetEquivalence checks
+// this for single genotypes.
+type Equivalence struct {
+	name      string
+	canonical func(flower.GeneticDistribution) flower.GeneticDistribution
+}
+
+// NewEquivalence creates an equivalence under which distributions are
+// equivalent if canonical maps them to the same distribution.
+func NewEquivalence(name string, canonical func(flower.GeneticDistribution) flower.GeneticDistribution) *Equivalence {
+	return &Equivalence{name, canonical}
+}
+
+func (eq *Equivalence) Name() string { return eq.name }
+
+// Canonical returns the canonical distribution equivalent to gd.
+func (eq *Equivalence) Canonical(gd flower.GeneticDistribution) flower.GeneticDistribution {
+	return eq.canonical(gd)
+}
+
+// IdentityEquivalence treats distributions as equivalent only if they are
+// equal. It is the default equivalence.
+var IdentityEquivalence *Equivalence = NewEquivalence("identity", func(gd flower.GeneticDistribution) flower.GeneticDistribution { return gd })
+
+// GeneEquivalence treats distributions as equivalent if their marginal
+// distributions over the given genes match, projecting away all other genes.
+// Since genes are inherited independently, breeding respects this
+// equivalence; but the graph's tests & search predicate must depend only on the
+// given genes. (Phenotype tests usually depend on every gene.) Species.RelevantGenes can determine which genes a predicate
+// depends on.
+func GeneEquivalence(genes ...int) *Equivalence {
+	genes = append([]int(nil), genes...)
+	geneStrs := make([]string, len(genes))
+	for i, gene := range genes {
+		geneStrs[i] = fmt.Sprintf("%d", gene)
+	}
+	return NewEquivalence(fmt.Sprintf("genes{%s}", strings.Join(geneStrs, ",")), func(gd flower.GeneticDistribution) flower.GeneticDistribution {
+		return gd.Marginal(genes...)
+	})
+}
+
+// respects determines if the test described by ts treats all equivalent
+// genotypes alike: keeping all or none of them, &, for tests depending on the
+// parents' phenotypes, giving them all the same phenotype.
+func (eq *Equivalence) respects(ts testSpec) bool {
+	// alike determines if f is the same for all equivalent genotypes.
+	alike := func(f func(flower.Genotype) interface{}) bool {
+		vals := map[flower.GeneticDistribution]interface{}{}
+		for _, g := range ts.species.Genotypes() {
+			k := eq.canonical(g.ToGeneticDistribution())
+			if val, ok := vals[k]; ok && val != f(g) {
+				return false
+			}
+			vals[k] = f(g)
+		}
+		return true
+	}
+
+	switch ts.Op {
+	case "none":
+		return true
+	case "phenotype":
+		keep := map[string]bool{}
+		for _, p := range ts.Phenotypes {
+			keep[p] = true
+		}
+		return alike(func(g flower.Genotype) interface{} { return keep[ts.species.RenderPhenotype(ts.species.Phenotype(g))] })
+	case "matches-parent", "unlike-parents":
+		return alike(func(g flower.Genotype) interface{} { return ts.species.Phenotype(g) })
+	default:
+		for _, sub := range ts.Tests {
+			if !eq.respects(sub) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SetEquivalence sets the equivalence used to merge distributions. It should be
+// called before the graph is expanded. If several initial flowers are
+// equivalent, all are kept, but only the first is merged with bred flowers. An
+// error is returned, & the equivalence is not set, if one of the graph's tests
+// distinguishes between equivalent genotypes.
+func (g *Graph) SetEquivalence(eq *Equivalence) error {
+	for _, t := range g.tests {
+		if !eq.respects(t.spec) {
+			return fmt.Errorf("test %q distinguishes between flowers equivalent under %s", t.Name(), eq.Name())
+		}
+	}
+	g.equiv = eq
+	g.vertIndex = vertexIndex{}
+	for _, v := range g.verts {
+		_, v.canonical = g.keys(v.dist.sparse(), v.dist.dense())
+		v.next = nil
+		if g.vertIndex.get(v.canonical) == nil {
+			g.vertIndex.put(v)
+		}
+	}
+	return nil
+}
+
+// Equivalence returns the equivalence used to merge distributions.
+func (g *Graph) Equivalence() *Equivalence { return g.equiv }
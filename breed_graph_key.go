@@ -0,0 +1,91 @@
+package breedgraph
+
+import (
+	"fmt"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// distKey is a compact representation of a genetic distribution, used to store
+// & look up the distributions in a graph. It holds the binary encoding of the
+// distribution (only the genotypes with non-zero odds, each followed by its
+// varint-encoded odds), along with a precomputed hash of it. Distributions are
+// decoded into sparse form only while being bred.
+type distKey struct {
+	hash uint64
+	enc  string
+}
+
+func newDistKey(gd flower.SparseGeneticDistribution) distKey {
+	var buf [64]byte
+	enc := gd.AppendBinary(buf[:0])
+
+	// FNV-1a, over the encoding.
+	hash := uint64(14695981039346656037)
+	for _, b := range enc {
+		hash ^= uint64(b)
+		hash *= 1099511628211
+	}
+	return distKey{hash, string(enc)}
+}
+
+// sparse decodes the distribution.
+func (k distKey) sparse() flower.SparseGeneticDistribution {
+	var rslt flower.SparseGeneticDistribution
+	if err := rslt.UnmarshalBinary([]byte(k.enc)); err != nil {
+		panic(fmt.Sprintf("couldn't decode distribution key: %v", err))
+	}
+	return rslt
+}
+
+// dense decodes the distribution.
+func (k distKey) dense() flower.GeneticDistribution {
+	var rslt flower.GeneticDistribution
+	k.denseInto(&rslt)
+	return rslt
+}
+
+// denseInto decodes the distribution into gd, overwriting its contents.
+func (k distKey) denseInto(gd *flower.GeneticDistribution) {
+	if err := gd.UnmarshalBinary([]byte(k.enc)); err != nil {
+		panic(fmt.Sprintf("couldn't decode distribution key: %v", err))
+	}
+}
+
+// vertexIndex indexes vertices by the canonical keys of their distributions.
+// Vertices whose keys share a hash are chained through their next fields.
+type vertexIndex map[uint64]*vertex
+
+func (vi vertexIndex) get(k distKey) *vertex {
+	for v := vi[k.hash]; v != nil; v = v.next {
+		if v.canonical.enc == k.enc {
+			return v
+		}
+	}
+	return nil
+}
+
+// remove removes v from the index, if it is present.
+func (vi vertexIndex) remove(v *vertex) {
+	h := v.canonical.hash
+	if vi[h] == v {
+		if v.next == nil {
+			delete(vi, h)
+		} else {
+			vi[h] = v.next
+		}
+		v.next = nil
+		return
+	}
+	for p := vi[h]; p != nil; p = p.next {
+		if p.next == v {
+			p.next, v.next = v.next, nil
+			return
+		}
+	}
+}
+
+func (vi vertexIndex) put(v *vertex) {
+	v.next = vi[v.canonical.hash]
+	vi[v.canonical.hash] = v
+}
@@ -0,0 +1,116 @@
+package breedgraph
+
+import "github.com/BranLwyd/acnh_flowers/flower"
+
+// Plan searches for the lowest-cost way to breed a flower known to have the
+// target genotype, expanding the graph at most steps times. It returns the
+// vertex for the target, or ok = false if the target can't be bred in that many
+// steps.
+//
+// Plan prunes a forward search, rather than meeting a backward search in the
+// middle: it first works backward from the target to determine which genotypes
+// could be ancestors of the target within each number of generations, then
+// expands the graph forward, discarding new distributions that can't lead to
+// the target within the remaining steps. It discards no vertex on a path of at
+// most steps generations to the target, so it usually finds a path of the same
+// cost as expanding the graph steps times with Expand & searching it with
+// Search. (Expand may also improve the paths to existing vertices, so that the
+// path it finds takes more generations than it was expanded: three expansions
+// from rose seeds find a four-generation path to RRYYwwss roses costing 73,
+// while Plan finds a three-generation path costing 74.)
+//
+// Plan saves work only when it discards vertices before they are bred, i.e.
+// when few genotypes could be ancestors of the target; every pair of kept
+// vertices is still bred. Planning RRYYwwss roses three steps from seeds breeds
+// about a thirteenth as many pairs as expanding the graph (see BenchmarkPlan),
+// but every genotype could be an ancestor of blue roses within two
+// generations, so planning for blue roses is little faster than expanding the
+// graph.
+func (g *Graph) Plan(s flower.Species, target flower.Genotype, steps int) (_ Vertex, ok bool) {
+	v, ok, _ := g.plan(s, target, steps, func(keepPred func(flower.GeneticDistribution) bool) error {
+		g.Expand(keepPred)
+		return nil
+	})
+	return v, ok
+}
+
+// PlanWith is like Plan, but expands the graph using the given worker
+// processes. See ExpandWith.
+func (g *Graph) PlanWith(ws *Workers, s flower.Species, target flower.Genotype, steps int) (_ Vertex, ok bool, _ error) {
+	return g.plan(s, target, steps, func(keepPred func(flower.GeneticDistribution) bool) error {
+		return g.ExpandWith(ws, keepPred)
+	})
+}
+
+func (g *Graph) plan(s flower.Species, target flower.Genotype, steps int, expand func(keepPred func(flower.GeneticDistribution) bool) error) (_ Vertex, ok bool, _ error) {
+	isTarget := func(gd flower.GeneticDistribution) bool {
+		rslt := true
+		gd.Visit(func(g flower.Genotype, _ uint64) bool {
+			rslt = g == target
+			return rslt
+		})
+		return rslt
+	}
+
+	ancestors := ancestorGenotypes(s, target, steps)
+	for i := 0; i < steps; i++ {
+		remaining := steps - i - 1
+		keepPred := func(gd flower.GeneticDistribution) bool { return ancestors[remaining].intersects(gd) }
+		if remaining == 0 {
+			// On the last step, keep only the target, since we won't be
+			// expanding any more from it.
+			keepPred = isTarget
+		}
+		if err := expand(keepPred); err != nil {
+			return Vertex{}, false, err
+		}
+	}
+	v, ok := g.Search(isTarget)
+	return v, ok, nil
+}
+
+// genotypeSet is a set of genotypes, indexed by genotype.
+type genotypeSet [256]bool
+
+// intersects determines if any genotype possible in gd is in the set.
+func (gs *genotypeSet) intersects(gd flower.GeneticDistribution) bool {
+	rslt := false
+	gd.Visit(func(g flower.Genotype, _ uint64) bool {
+		rslt = gs[g]
+		return !rslt
+	})
+	return rslt
+}
+
+// ancestorGenotypes works backward from the target genotype, returning for each
+// number of generations k in [0, steps] the set of genotypes that can be an
+// ancestor of the target at most k generations back. (Generation 0 contains
+// only the target itself.)
+func ancestorGenotypes(s flower.Species, target flower.Genotype, steps int) []genotypeSet {
+	type breeding struct {
+		parents [2]flower.Genotype
+		child   flower.GeneticDistribution
+	}
+	gs := s.Genotypes()
+	var breedings []breeding
+	for i, ga := range gs {
+		for _, gb := range gs[i:] {
+			breedings = append(breedings, breeding{[2]flower.Genotype{ga, gb}, ga.ToGeneticDistribution().Breed(gb.ToGeneticDistribution())})
+		}
+	}
+
+	rslt := make([]genotypeSet, steps+1)
+	rslt[0][target] = true
+	for k := 1; k <= steps; k++ {
+		rslt[k] = rslt[k-1]
+		for _, b := range breedings {
+			if rslt[k][b.parents[0]] && rslt[k][b.parents[1]] {
+				continue
+			}
+			if rslt[k-1].intersects(b.child) {
+				rslt[k][b.parents[0]], rslt[k][b.parents[1]] = true, true
+			}
+		}
+	}
+	return rslt
+}
@@ -0,0 +1,118 @@
+package breedgraph
+
+// PathStats summarizes the lowest-cost path to a flower.
+type PathStats struct {
+	Cost     float64 // the expected number of breedings needed
+	Steps    int     // the number of generations of breeding
+	Tests    int     // the number of breedings whose children must be tested
+	Flowers  int     // the number of distinct flowers involved, including the result
+	Priority int     // the priority of the test applied to the final breeding, or 0 if there is none
+}
+
+// Policy ranks the possible ways of breeding a flower. It determines which
+// predecessor is kept for each vertex as a graph is expanded, & which vertex is
+// returned by Search.
+type Policy struct {
+	name, description string
+	less              func(a, b PathStats) bool
+}
+
+// NewPolicy creates a policy which prefers a path with stats a over one with
+// stats b if less(a, b) is true. less must be a strict order: no path may be
+// better than itself, & if a is better than b & b than c, a must be better
+// than c. Expanding a graph panics if less is found not to be strict.
+func NewPolicy(name, description string, less func(a, b PathStats) bool) *Policy {
+	return &Policy{name, description, less}
+}
+
+func (p *Policy) Name() string        { return p.name }
+func (p *Policy) Description() string { return p.description }
+
+var (
+	// MinCostPolicy prefers the path with the lowest expected number of
+	// breedings, breaking ties by test priority. It is the default policy.
+	MinCostPolicy *Policy = NewPolicy("min-cost", "lowest expected number of breedings, then simplest final test", minCost)
+
+	// FewestStepsPolicy prefers the path with the fewest generations, then
+	// follows MinCostPolicy.
+	FewestStepsPolicy *Policy = NewPolicy("fewest-steps", "fewest generations, then lowest expected number of breedings", func(a, b PathStats) bool {
+		if a.Steps != b.Steps {
+			return a.Steps < b.Steps
+		}
+		return minCost(a, b)
+	})
+
+	// FewestTestsPolicy prefers the path requiring the fewest tested
+	// breedings, then follows MinCostPolicy.
+	FewestTestsPolicy *Policy = NewPolicy("fewest-tests", "fewest breedings needing tests, then lowest expected number of breedings", func(a, b PathStats) bool {
+		if a.Tests != b.Tests {
+			return a.Tests < b.Tests
+		}
+		return minCost(a, b)
+	})
+
+	// FewestFlowersPolicy prefers the path involving the fewest distinct
+	// flowers, then follows MinCostPolicy.
+	FewestFlowersPolicy *Policy = NewPolicy("fewest-flowers", "fewest distinct flowers, then lowest expected number of breedings", func(a, b PathStats) bool {
+		if a.Flowers != b.Flowers {
+			return a.Flowers < b.Flowers
+		}
+		return minCost(a, b)
+	})
+)
+
+func minCost(a, b PathStats) bool {
+	return a.Cost < b.Cost || (a.Cost == b.Cost && a.Priority < b.Priority)
+}
+
+// Policies returns the built-in policies, default first.
+func Policies() []*Policy {
+	return []*Policy{MinCostPolicy, FewestStepsPolicy, FewestTestsPolicy, FewestFlowersPolicy}
+}
+
+// PolicyByName returns the built-in policy with the given name.
+func PolicyByName(name string) (_ *Policy, ok bool) {
+	for _, p := range Policies() {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// SetPolicy sets the policy used to rank paths. It should be called before the
+// graph is expanded.
+func (g *Graph) SetPolicy(p *Policy) { g.policy = p }
+
+// Policy returns the policy used to rank paths.
+func (g *Graph) Policy() *Policy { return g.policy }
+
+func (e *edge) pathStats() PathStats {
+	p0, p1 := e.pred[0], e.pred[1]
+	p0.cache()
+	p1.cache()
+
+	// The result of this edge may not yet be a vertex, so count it separately.
+	stats := PathStats{Cost: e.cost, Steps: p0.stats.Steps + 1, Flowers: 1, Priority: e.test.Priority()}
+	if p1.stats.Steps >= p0.stats.Steps {
+		stats.Steps = p1.stats.Steps + 1
+	}
+	if e.test != NoTest {
+		stats.Tests++
+	}
+	unionAncestors(p0.ancestors, p1.ancestors, func(a *vertex) {
+		stats.Flowers++
+		if a.pred != nil {
+			stats.Cost += a.pred.cost
+			if a.pred.test != NoTest {
+				stats.Tests++
+			}
+		}
+	})
+	return stats
+}
+
+func (v *vertex) pathStats() PathStats {
+	v.cache()
+	return v.stats
+}
@@ -0,0 +1,349 @@
+package breedgraph
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// Workers is a set of worker processes which expand graphs on behalf of
+// another process. Each worker process runs ServeExpandWorker on its standard
+// input & output. An expansion is sharded across the workers: of n workers,
+// worker k breeds each vertex i with i % n == k with the vertices after it.
+type Workers struct {
+	workers []*worker
+	err     error // set once an expansion fails, after which the workers can't be used
+
+	// The graph last expanded by the workers, & the number of its flowers'
+	// distributions they hold. Workers keep the distributions sent to them, so
+	// that further expansions of the same graph send only new distributions.
+	graph *Graph
+	sent  int
+}
+
+type worker struct {
+	cmd   *exec.Cmd
+	in    io.WriteCloser
+	bufIn *bufio.Writer
+	enc   *gob.Encoder
+	dec   *gob.Decoder
+}
+
+// StartWorkers starts each of the given commands as a worker process. The
+// commands' standard input & output must not be set.
+func StartWorkers(cmds []*exec.Cmd) (*Workers, error) {
+	ws := &Workers{}
+	for _, cmd := range cmds {
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("couldn't start worker: %v", err)
+		}
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("couldn't start worker: %v", err)
+		}
+		if err := cmd.Start(); err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("couldn't start worker: %v", err)
+		}
+		bufIn := bufio.NewWriter(in)
+		ws.workers = append(ws.workers, &worker{cmd, in, bufIn, gob.NewEncoder(bufIn), gob.NewDecoder(bufio.NewReader(out))})
+	}
+	return ws, nil
+}
+
+// Close stops the worker processes, waiting for them to exit.
+func (ws *Workers) Close() error {
+	var rsltErr error
+	for _, w := range ws.workers {
+		w.in.Close()
+		if err := w.cmd.Wait(); err != nil && rsltErr == nil {
+			rsltErr = fmt.Errorf("worker failed: %v", err)
+		}
+	}
+	ws.workers = nil
+	return rsltErr
+}
+
+// expandJob asks a worker to breed its shard of the pairs of flowers in an
+// expansion.
+type expandJob struct {
+	Shard, ShardCnt int
+	Frontier        int // pairs of flowers before the frontier have already been bred
+	Tests           []testSpec
+
+	// The distributions of the flowers to breed, by ID. The worker keeps the
+	// first Known distributions from earlier jobs, discarding any others; Dists
+	// holds the rest.
+	Known int
+	Dists []flower.SparseGeneticDistribution
+
+	// The definitions of the species used by Tests, so that workers can
+	// reconstruct them even if they were created with flower.NewSpecies.
+	Species []flower.SpeciesSpec
+}
+
+// expandRow holds a worker's results for breeding one flower, in order.
+type expandRow struct {
+	I       int
+	Results []expandRowResult
+}
+
+type expandRowResult struct {
+	J, Test int
+	Cost    float64
+	Dist    flower.SparseGeneticDistribution
+}
+
+// ExpandWith is like Expand, but breeds flowers in the given worker
+// processes. The resulting graph is the same as that produced by Expand. If a
+// worker fails, an error is returned & the flowers added by the expansion are
+// removed, though paths to existing flowers may have been improved; expanding
+// the graph again completes the expansion. The workers can't be used again
+// after such a failure.
+func (g *Graph) ExpandWith(ws *Workers, keepPred func(flower.GeneticDistribution) bool) error {
+	if ws.err != nil {
+		return fmt.Errorf("workers unusable after earlier failure: %v", ws.err)
+	}
+	if len(ws.workers) == 0 {
+		return fmt.Errorf("no workers")
+	}
+	initialVertCnt := len(g.verts)
+	verts, gds := g.verts[:initialVertCnt], g.distributions(initialVertCnt)
+	tests := make([]testSpec, len(g.tests))
+	for i, t := range g.tests {
+		tests[i] = t.spec
+	}
+	species, err := speciesSpecs(tests)
+	if err != nil {
+		return err
+	}
+	known := 0
+	if ws.graph == g {
+		known = ws.sent
+	}
+
+	ws.graph, ws.sent = g, initialVertCnt
+	for k, w := range ws.workers {
+		job := expandJob{k, len(ws.workers), g.vertFrontier, tests, known, gds[known:], species}
+		if err := w.enc.Encode(job); err != nil {
+			ws.err = fmt.Errorf("couldn't send job to worker %d: %v", k, err)
+			return ws.err
+		}
+		if err := w.bufIn.Flush(); err != nil {
+			ws.err = fmt.Errorf("couldn't send job to worker %d: %v", k, err)
+			return ws.err
+		}
+	}
+
+	// Receive results. Each worker's results are received by its own
+	// goroutine, which reports any failure on errCh before closing its results
+	// channel.
+	workerCnt := len(ws.workers)
+	errCh := make(chan error, workerCnt)
+	rsltsChs := make([]chan []expandResult, workerCnt)
+	for k, w := range ws.workers {
+		rsltsChs[k] = make(chan []expandResult, 1)
+		go func(k int, w *worker, rsltsCh chan<- []expandResult) {
+			defer close(rsltsCh)
+			for i := k; i < initialVertCnt; i += workerCnt {
+				var row expandRow
+				if err := w.dec.Decode(&row); err != nil {
+					errCh <- fmt.Errorf("couldn't receive results from worker %d: %v", k, err)
+					return
+				}
+				if row.I != i {
+					errCh <- fmt.Errorf("worker %d sent results for flower %d, want %d", k, row.I, i)
+					return
+				}
+				rslts := make([]expandResult, len(row.Results))
+				for idx, r := range row.Results {
+					if r.J < g.minJ(i) || r.J >= initialVertCnt || r.Test < 0 || r.Test >= len(g.tests) || r.Dist.IsZero() {
+						errCh <- fmt.Errorf("worker %d sent invalid result (flower %d, flower %d, test %d)", k, i, r.J, r.Test)
+						return
+					}
+					rslts[idx] = g.result(verts, i, r.J, r.Test, r.Dist, r.Cost, keepPred)
+				}
+				rsltsCh <- rslts
+			}
+		}(k, w, rsltsChs[k])
+	}
+
+	if !g.merge(rsltsChs, gds, func([]expandResult) {}) {
+		// The best predecessors of existing vertices are bred from existing
+		// vertices, so the new vertices can be removed.
+		g.truncate(initialVertCnt)
+		ws.err = <-errCh
+		// Let the remaining goroutines finish.
+		for _, rsltsCh := range rsltsChs {
+			go func(rsltsCh <-chan []expandResult) {
+				for range rsltsCh {
+				}
+			}(rsltsCh)
+		}
+		return ws.err
+	}
+	return nil
+}
+
+// ServeExpandWorker serves as a worker process for a coordinating process's
+// Workers, reading jobs from r & writing results to w until r is exhausted.
+// Each job is handled by a single goroutine, so a worker process should be
+// started for each available CPU.
+func ServeExpandWorker(r io.Reader, w io.Writer) error {
+	dec := gob.NewDecoder(bufio.NewReader(r))
+	bufW := bufio.NewWriter(w)
+	enc := gob.NewEncoder(bufW)
+	var dists []flower.SparseGeneticDistribution
+	for {
+		var job expandJob
+		if err := dec.Decode(&job); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("couldn't receive job: %v", err)
+		}
+		if job.ShardCnt <= 0 || job.Shard < 0 || job.Shard >= job.ShardCnt {
+			return fmt.Errorf("invalid shard %d of %d", job.Shard, job.ShardCnt)
+		}
+		if job.Known < 0 || job.Known > len(dists) {
+			return fmt.Errorf("job builds on %d distributions, have %d", job.Known, len(dists))
+		}
+		dists = append(dists[:job.Known], job.Dists...)
+		species := map[string]flower.Species{}
+		for _, spec := range job.Species {
+			s, err := flower.NewSpecies(spec)
+			if err != nil {
+				return fmt.Errorf("couldn't create species %q: %v", spec.Name, err)
+			}
+			species[spec.Name] = s
+		}
+		tests := make([]*Test, len(job.Tests))
+		for i, ts := range job.Tests {
+			t, err := ts.test(species)
+			if err != nil {
+				return fmt.Errorf("couldn't create test: %v", err)
+			}
+			tests[i] = t
+		}
+
+		for i := job.Shard; i < len(dists); i += job.ShardCnt {
+			minJ := job.Frontier
+			if i > minJ {
+				minJ = i
+			}
+			row := expandRow{I: i}
+			breedRow(tests, dists, i, minJ, func(j, test int, gd flower.SparseGeneticDistribution, cost float64) {
+				row.Results = append(row.Results, expandRowResult{j, test, cost, gd})
+			})
+			if err := enc.Encode(row); err != nil {
+				return fmt.Errorf("couldn't send results: %v", err)
+			}
+		}
+		if err := bufW.Flush(); err != nil {
+			return fmt.Errorf("couldn't send results: %v", err)
+		}
+	}
+}
+
+// testSpec describes how a test was constructed, so that it can be
+// reconstructed in another process.
+type testSpec struct {
+	Op         string // one of "none", "phenotype", "matches-parent", "unlike-parents", "and", "or", "not"
+	Species    string
+	Phenotypes []string // names of phenotypes of Species
+	Parent     int
+	Tests      []testSpec
+
+	species flower.Species // the species named by Species; not sent to other processes
+}
+
+// speciesSpecs returns the definitions of the species used by the given tests.
+// Since workers look up species by name, different species used by the tests
+// must have different names.
+func speciesSpecs(tests []testSpec) ([]flower.SpeciesSpec, error) {
+	var rslt []flower.SpeciesSpec
+	seen := map[string]flower.Species{}
+	var visit func(ts testSpec) error
+	visit = func(ts testSpec) error {
+		if ts.Species != "" {
+			if s, ok := seen[ts.Species]; !ok {
+				seen[ts.Species] = ts.species
+				rslt = append(rslt, ts.species.Spec())
+			} else if !reflect.DeepEqual(s, ts.species) {
+				return fmt.Errorf("tests use different species named %q", ts.Species)
+			}
+		}
+		for _, sub := range ts.Tests {
+			if err := visit(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, ts := range tests {
+		if err := visit(ts); err != nil {
+			return nil, err
+		}
+	}
+	return rslt, nil
+}
+
+// test reconstructs the test, using the given species by name.
+func (ts testSpec) test(species map[string]flower.Species) (*Test, error) {
+	var s flower.Species
+	switch ts.Op {
+	case "phenotype", "matches-parent", "unlike-parents":
+		var ok bool
+		if s, ok = species[ts.Species]; !ok {
+			return nil, fmt.Errorf("unknown species %q", ts.Species)
+		}
+	}
+	var tests []*Test
+	for _, sub := range ts.Tests {
+		t, err := sub.test(species)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, t)
+	}
+
+	switch ts.Op {
+	case "none":
+		return NoTest, nil
+	case "phenotype":
+		var phenotypes []flower.Phenotype
+		for _, name := range ts.Phenotypes {
+			p, err := s.ParsePhenotype(name)
+			if err != nil {
+				return nil, err
+			}
+			phenotypes = append(phenotypes, p)
+		}
+		return PhenotypeTest(s, phenotypes...), nil
+	case "matches-parent":
+		if ts.Parent != 0 && ts.Parent != 1 {
+			return nil, fmt.Errorf("invalid parent %d", ts.Parent)
+		}
+		return MatchesParentTest(s, ts.Parent), nil
+	case "unlike-parents":
+		return UnlikeParentsTest(s), nil
+	case "and":
+		return And(tests...), nil
+	case "or":
+		return Or(tests...), nil
+	case "not":
+		if len(tests) != 1 {
+			return nil, fmt.Errorf("negation of %d tests", len(tests))
+		}
+		return Not(tests[0]), nil
+	default:
+		return nil, fmt.Errorf("unknown test op %q", ts.Op)
+	}
+}
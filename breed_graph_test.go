@@ -0,0 +1,1104 @@
+package breedgraph
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// workerEnv is set in the environment of worker processes started by tests.
+// Rather than running tests, such processes serve as expansion workers if it is
+// "serve", fail immediately if it is "fail", or fail after sending some results
+// if it is "partial".
+const workerEnv = "BREEDGRAPH_TEST_WORKER"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(workerEnv) {
+	case "serve":
+		if err := ServeExpandWorker(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Worker failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "fail":
+		os.Exit(1)
+	case "partial":
+		// Fail after sending some results.
+		ServeExpandWorker(os.Stdin, &limitWriter{os.Stdout, 1 << 11})
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// limitWriter writes to w, failing once n bytes have been written.
+type limitWriter struct {
+	w io.Writer
+	n int
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > lw.n {
+		n, _ := lw.w.Write(p[:lw.n])
+		lw.n -= n
+		return n, errors.New("write limit reached")
+	}
+	n, err := lw.w.Write(p)
+	lw.n -= n
+	return n, err
+}
+
+// startTestWorkers starts n worker processes running this test binary, with
+// workerEnv set to mode.
+func startTestWorkers(t *testing.T, n int, mode string) *Workers {
+	t.Helper()
+	cmds := make([]*exec.Cmd, n)
+	for i := range cmds {
+		cmds[i] = exec.Command(os.Args[0])
+		cmds[i].Env = append(os.Environ(), workerEnv+"="+mode)
+		cmds[i].Stderr = os.Stderr
+	}
+	ws, err := StartWorkers(cmds)
+	if err != nil {
+		t.Fatalf("Couldn't start workers: %v", err)
+	}
+	return ws
+}
+
+// roseGraph returns a graph of roses, starting from the seed flowers & expanded
+// the given number of times.
+func roseGraph(steps int) *Graph {
+	roses := flower.Roses()
+	var seeds []flower.GeneticDistribution
+	for _, g := range roses.Seeds() {
+		seeds = append(seeds, g.ToGeneticDistribution())
+	}
+	tests := append([]*Test{NoTest}, PhenotypeTestsUpToSize(roses, 1)...)
+	g := NewGraph(tests, seeds)
+	for i := 0; i < steps; i++ {
+		g.Expand(func(flower.GeneticDistribution) bool { return true })
+	}
+	return g
+}
+
+func TestVisitPathInOrder(t *testing.T) {
+	g := roseGraph(2)
+	g.VisitVertices(func(v Vertex) {
+		type visit struct {
+			v   *vertex
+			e   *edge
+			gen int
+		}
+		var visits []visit
+		v.VisitPathInOrder(func(v Vertex, gen int) {
+			visits = append(visits, visit{v: v.v, gen: gen})
+		}, func(e Edge, gen int) {
+			visits = append(visits, visit{e: e.e, gen: gen})
+		})
+
+		// Each edge must follow its parents & immediately precede its child;
+		// generations must be consistent & nondecreasing.
+		gens := map[*vertex]int{}
+		lastGen := 0
+		for i, x := range visits {
+			if x.gen < lastGen {
+				t.Fatalf("Visit %d has generation %d, after generation %d", i, x.gen, lastGen)
+			}
+			lastGen = x.gen
+
+			if x.e != nil {
+				for _, p := range x.e.pred {
+					pGen, ok := gens[p]
+					if !ok {
+						t.Fatalf("Edge visited (visit %d) before its parent", i)
+					}
+					if pGen >= x.gen {
+						t.Errorf("Edge (visit %d) has generation %d, but its parent has generation %d", i, x.gen, pGen)
+					}
+				}
+				if i+1 == len(visits) || visits[i+1].v != x.e.succ || visits[i+1].gen != x.gen {
+					t.Fatalf("Edge (visit %d) not immediately followed by its child", i)
+				}
+				continue
+			}
+			if _, ok := gens[x.v]; ok {
+				t.Fatalf("Vertex visited twice (visit %d)", i)
+			}
+			if (x.v.pred == nil) != (x.gen == 0) {
+				t.Errorf("Vertex (visit %d) has generation %d, but predecessor %v", i, x.gen, x.v.pred)
+			}
+			gens[x.v] = x.gen
+		}
+		if last := visits[len(visits)-1]; last.v != v.v {
+			t.Errorf("Last visit was not to the path's target vertex")
+		}
+
+		// Traversal order must be the same each time.
+		var again []visit
+		v.VisitPathInOrder(func(v Vertex, gen int) {
+			again = append(again, visit{v: v.v, gen: gen})
+		}, func(e Edge, gen int) {
+			again = append(again, visit{e: e.e, gen: gen})
+		})
+		if fmt.Sprint(visits) != fmt.Sprint(again) {
+			t.Errorf("VisitPathInOrder gave different orders on repeated calls")
+		}
+	})
+}
+
+func TestPlan(t *testing.T) {
+	roses := flower.Roses()
+	forward := roseGraph(2)
+	for _, test := range []struct {
+		target string
+		pruned bool // whether Plan must breed fewer pairs than forward search
+	}{
+		// Nearly every flower bred from seeds could be a parent of
+		// RryyWwSs, so every pair is bred on the second step.
+		{"RryyWwSs", false},
+		{"RRYyWWSs", true},
+	} {
+		target, err := roses.ParseGenotype(test.target)
+		if err != nil {
+			t.Fatalf("Couldn't parse genotype: %v", err)
+		}
+		isTarget := func(gd flower.GeneticDistribution) bool { return gd == target.ToGeneticDistribution() }
+
+		// Plan should find a path as cheap as exhaustive forward search,
+		// while keeping fewer vertices & breeding no more pairs.
+		want, ok := forward.Search(isTarget)
+		if !ok {
+			t.Fatalf("Forward search found no path to %s", test.target)
+		}
+		g := roseGraph(0)
+		got, ok := g.Plan(roses, target, 2)
+		if !ok {
+			t.Fatalf("Plan found no path to %s", test.target)
+		}
+		if got.Value() != want.Value() || got.PathCost() != want.PathCost() {
+			t.Errorf("Plan(%s) found %v with cost %v, want %v with cost %v", test.target, got.Value(), got.PathCost(), want.Value(), want.PathCost())
+		}
+		if len(g.verts) >= len(forward.verts) {
+			t.Errorf("Plan(%s) kept %d vertices, want fewer than forward search's %d", test.target, len(g.verts), len(forward.verts))
+		}
+		if g.breedings > forward.breedings || (test.pruned && g.breedings == forward.breedings) {
+			t.Errorf("Plan(%s) bred %d pairs, forward search bred %d", test.target, g.breedings, forward.breedings)
+		}
+
+		// The target can't be bred in a single step.
+		if _, ok := roseGraph(0).Plan(roses, target, 1); ok {
+			t.Errorf("Plan(%s) found a single-step path, want none", test.target)
+		}
+	}
+
+	// RRYYwwss roses take three steps to breed, & have few possible
+	// ancestors, so most pairs are never bred. Forward search finds a cheaper
+	// path, since its last expansion improves an existing vertex through a
+	// third generation, giving a four-generation path.
+	target, err := roses.ParseGenotype("RRYYwwss")
+	if err != nil {
+		t.Fatalf("Couldn't parse genotype: %v", err)
+	}
+	isTarget := func(gd flower.GeneticDistribution) bool { return gd == target.ToGeneticDistribution() }
+	forward = roseGraph(2)
+	forward.Expand(isTarget)
+	want, ok := forward.Search(isTarget)
+	if !ok {
+		t.Fatalf("Forward search found no path to RRYYwwss")
+	}
+	g := roseGraph(0)
+	got, ok := g.Plan(roses, target, 3)
+	if !ok {
+		t.Fatalf("Plan found no path to RRYYwwss")
+	}
+	if stats := got.PathStats(); stats.Steps != 3 || stats.Cost != 74 || want.PathStats().Steps != 4 || want.PathCost() != 73 {
+		t.Errorf("Plan(RRYYwwss) found %d-step path costing %v, forward search found %d-step path costing %v; want 3 steps costing 74 & 4 steps costing 73", stats.Steps, stats.Cost, want.PathStats().Steps, want.PathCost())
+	}
+	if g.breedings*10 > forward.breedings {
+		t.Errorf("Plan(RRYYwwss) bred %d pairs, want at most a tenth of forward search's %d", g.breedings, forward.breedings)
+	}
+}
+
+func TestComposableTests(t *testing.T) {
+	roses := flower.Roses()
+	parse := func(str string) flower.GeneticDistribution {
+		gd, err := roses.ParseGeneticDistribution(str)
+		if err != nil {
+			t.Fatalf("Couldn't parse genetic distribution %q: %v", str, err)
+		}
+		return gd
+	}
+	// Red & yellow seeds produce orange & yellow children, equally likely.
+	parents := [2]flower.GeneticDistribution{parse("RRyyWWSs"), parse("rrYYWWss")}
+	child := parents[0].Breed(parents[1])
+	orange, yellow := parse("RrYyWWss"), parse("RrYyWWSs")
+
+	for _, test := range []struct {
+		test     *Test
+		wantName string
+		want     flower.GeneticDistribution
+		wantCost float64
+	}{
+		{NoTest, "", child, 1},
+		{MatchesParentTest(roses, 0), "P=Parent1", flower.GeneticDistribution{}, 0},
+		{MatchesParentTest(roses, 1), "P=Parent2", yellow, 2},
+		{UnlikeParentsTest(roses), "P∉Parents", orange, 2},
+		{Not(PhenotypeTest(roses, flower.Orange)), "!P∈{Orange}", yellow, 2},
+		{Or(PhenotypeTest(roses, flower.Orange), MatchesParentTest(roses, 1)), "P∈{Orange} | P=Parent2", child, 1},
+		{And(PhenotypeTest(roses, flower.Orange, flower.Yellow), Not(UnlikeParentsTest(roses))), "P∈{Orange,Yellow} & !P∉Parents", yellow, 2},
+	} {
+		if got := test.test.Name(); got != test.wantName {
+			t.Errorf("Test name = %q, want %q", got, test.wantName)
+		}
+		got, cost := test.test.TestBred(parents, child)
+		if got != test.want || cost != test.wantCost {
+			t.Errorf("%q.TestBred() = (%s, %v), want (%s, %v)", test.test.Name(), roses.RenderGeneticDistribution(got), cost, roses.RenderGeneticDistribution(test.want), test.wantCost)
+		}
+	}
+
+	// Parent-relative tests can't be applied if the parents are unknown.
+	if got, _ := MatchesParentTest(roses, 1).Test(child); !got.IsZero() {
+		t.Errorf("MatchesParentTest with unknown parents = %s, want zero distribution", roses.RenderGeneticDistribution(got))
+	}
+
+	// Only the first & second parents can be matched.
+	for _, parent := range []int{-1, 2} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MatchesParentTest(roses, %d) did not panic", parent)
+				}
+			}()
+			MatchesParentTest(roses, parent)
+		}()
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	roses := flower.Roses()
+	isOrange := func(gd flower.GeneticDistribution) bool {
+		pd := roses.PhenotypeDistribution(gd)
+		return len(pd) == 1 && pd[flower.Orange] != 0
+	}
+
+	stats := map[*Policy]PathStats{}
+	for _, p := range Policies() {
+		g := roseGraph(0)
+		g.SetPolicy(p)
+		g.Expand(func(flower.GeneticDistribution) bool { return true })
+		g.Expand(func(flower.GeneticDistribution) bool { return true })
+		v, ok := g.Search(isOrange)
+		if !ok {
+			t.Fatalf("Search with policy %q found no orange roses", p.Name())
+		}
+		stats[p] = v.PathStats()
+	}
+
+	// Orange roses can be bred directly from the red & yellow seeds.
+	if got, want := stats[FewestStepsPolicy], (PathStats{Cost: 2, Steps: 1, Tests: 1, Flowers: 3, Priority: 1}); got != want {
+		t.Errorf("Path found by fewest-steps policy has stats %+v, want %+v", got, want)
+	}
+	mc := stats[MinCostPolicy]
+	for _, p := range Policies() {
+		if stats[p].Cost < mc.Cost {
+			t.Errorf("Path found by %s policy is cheaper than path found by min-cost policy (%+v vs %+v)", p.Name(), stats[p], mc)
+		}
+	}
+	if got := stats[FewestTestsPolicy]; got.Tests > mc.Tests {
+		t.Errorf("Path found by fewest-tests policy has more tests than min-cost policy (%+v vs %+v)", got, mc)
+	}
+	if got := stats[FewestFlowersPolicy]; got.Flowers > mc.Flowers {
+		t.Errorf("Path found by fewest-flowers policy has more flowers than min-cost policy (%+v vs %+v)", got, mc)
+	}
+}
+
+// describeGraph renders a graph's vertices, in order, along with each vertex's
+// best predecessor.
+func describeGraph(g *Graph) string {
+	ids := map[*vertex]int{}
+	for i, v := range g.verts {
+		ids[v] = i
+	}
+	var sb strings.Builder
+	for i, v := range g.verts {
+		fmt.Fprintf(&sb, "%d: %v", i, v.dist.sparse())
+		if e := v.pred; e != nil {
+			fmt.Fprintf(&sb, " <- %d x %d [%q, %v]", ids[e.pred[0]], ids[e.pred[1]], e.test.Name(), e.cost)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func TestExpandDeterministic(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	var want string
+	for _, procs := range []int{1, 2, 3, 8} {
+		runtime.GOMAXPROCS(procs)
+		got := describeGraph(roseGraph(2))
+		if want == "" {
+			want = got
+			continue
+		}
+		if got != want {
+			t.Errorf("Graph expanded with GOMAXPROCS = %d differs from graph expanded with GOMAXPROCS = 1", procs)
+		}
+	}
+}
+
+func TestExpandWith(t *testing.T) {
+	roses := flower.Roses()
+	var seeds []flower.GeneticDistribution
+	for _, g := range roses.Seeds() {
+		seeds = append(seeds, g.ToGeneticDistribution())
+	}
+	// Include tests of every kind, to check that workers reconstruct them.
+	tests := append([]*Test{
+		NoTest,
+		MatchesParentTest(roses, 1),
+		UnlikeParentsTest(roses),
+		Not(Or(PhenotypeTest(roses, flower.Red), PhenotypeTest(roses, flower.White))),
+		And(PhenotypeTest(roses, flower.Red, flower.Orange), Not(MatchesParentTest(roses, 0))),
+	}, PhenotypeTestsUpToSize(roses, 1)...)
+	keepPreds := []func(flower.GeneticDistribution) bool{
+		func(flower.GeneticDistribution) bool { return true },
+		func(gd flower.GeneticDistribution) bool {
+			n := 0
+			gd.Visit(func(flower.Genotype, uint64) bool { n++; return true })
+			return n <= 4
+		},
+	}
+
+	want := NewGraph(tests, seeds)
+	for _, keepPred := range keepPreds {
+		want.Expand(keepPred)
+	}
+
+	// The workers keep distributions sent for one graph, which must not be
+	// used for another.
+	ws := startTestWorkers(t, 3, "serve")
+	for _, name := range []string{"first", "second"} {
+		got := NewGraph(tests, seeds)
+		for i, keepPred := range keepPreds {
+			if err := got.ExpandWith(ws, keepPred); err != nil {
+				t.Fatalf("ExpandWith (%s graph, step %d) failed: %v", name, i+1, err)
+			}
+		}
+		if describeGraph(got) != describeGraph(want) {
+			t.Errorf("Expanding the %s graph with workers gave a different graph than Expand", name)
+		}
+	}
+	if err := ws.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestServeExpandWorkerKeepsDistributions(t *testing.T) {
+	g := roseGraph(1)
+	gds := g.distributions(len(g.verts))
+	tests := make([]testSpec, len(g.tests))
+	for i, test := range g.tests {
+		tests[i] = test.spec
+	}
+	species, err := speciesSpecs(tests)
+	if err != nil {
+		t.Fatalf("speciesSpecs got unexpected error: %v", err)
+	}
+
+	// The second job sends only the distributions new since the first.
+	seedCnt := len(flower.Roses().Seeds())
+	var in bytes.Buffer
+	enc := gob.NewEncoder(&in)
+	for _, job := range []expandJob{
+		{0, 1, 0, tests, 0, gds[:seedCnt], species},
+		{0, 1, seedCnt, tests, seedCnt, gds[seedCnt:], species},
+	} {
+		if err := enc.Encode(job); err != nil {
+			t.Fatalf("Couldn't encode job: %v", err)
+		}
+	}
+	var out bytes.Buffer
+	if err := ServeExpandWorker(&in, &out); err != nil {
+		t.Fatalf("ServeExpandWorker got unexpected error: %v", err)
+	}
+
+	dec := gob.NewDecoder(&out)
+	for _, job := range []struct{ frontier, cnt int }{{0, seedCnt}, {seedCnt, len(gds)}} {
+		for i := 0; i < job.cnt; i++ {
+			var row expandRow
+			if err := dec.Decode(&row); err != nil {
+				t.Fatalf("Couldn't decode results: %v", err)
+			}
+			minJ := job.frontier
+			if i > minJ {
+				minJ = i
+			}
+			want := expandRow{I: i}
+			breedRow(g.tests, gds[:job.cnt], i, minJ, func(j, test int, gd flower.SparseGeneticDistribution, cost float64) {
+				want.Results = append(want.Results, expandRowResult{j, test, cost, gd})
+			})
+			if fmt.Sprint(row) != fmt.Sprint(want) {
+				t.Errorf("Worker sent results %v, want %v", row, want)
+			}
+		}
+	}
+
+	// A job can't build on distributions the worker doesn't have.
+	in.Reset()
+	if err := gob.NewEncoder(&in).Encode(expandJob{0, 1, 0, tests, 1, gds, species}); err != nil {
+		t.Fatalf("Couldn't encode job: %v", err)
+	}
+	if err := ServeExpandWorker(&in, ioutil.Discard); err == nil {
+		t.Errorf("ServeExpandWorker with unknown distributions succeeded")
+	}
+}
+
+func TestExpandWithSpeciesNameCollision(t *testing.T) {
+	spec := flower.Roses().Spec()
+	spec.Phenotypes["RRYYWWSS"] = "Black"
+	blackRoses, err := flower.NewSpecies(spec)
+	if err != nil {
+		t.Fatalf("NewSpecies got unexpected error: %v", err)
+	}
+	g := roseGraph(0)
+	g.tests = append(g.tests, PhenotypeTest(blackRoses, flower.Black))
+
+	ws := startTestWorkers(t, 2, "serve")
+	if err := g.ExpandWith(ws, func(flower.GeneticDistribution) bool { return true }); err == nil {
+		t.Errorf("ExpandWith with different species named %q succeeded", spec.Name)
+	}
+
+	// The workers remain usable.
+	if err := roseGraph(0).ExpandWith(ws, func(flower.GeneticDistribution) bool { return true }); err != nil {
+		t.Errorf("ExpandWith after rejected species got unexpected error: %v", err)
+	}
+	if err := ws.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestExpandWithNewSpecies(t *testing.T) {
+	// Workers must reconstruct species unknown to them, along with their
+	// phenotypes.
+	spec := flower.Roses().Spec()
+	spec.Name = "Golden Roses"
+	spec.PhenotypeSpecs = []flower.PhenotypeSpec{{PhenotypeInfo: flower.PhenotypeInfo{Name: "Gold", Aliases: []string{"Gilded"}, Color: "#d4af37"}}}
+	for _, g := range []string{"RRYYWWSS", "RRYYWWSs", "RRYyWWSS"} {
+		spec.Phenotypes[g] = "Gilded"
+	}
+	s, err := flower.NewSpecies(spec)
+	if err != nil {
+		t.Fatalf("NewSpecies got unexpected error: %v", err)
+	}
+	var seeds []flower.GeneticDistribution
+	for _, g := range s.Seeds() {
+		seeds = append(seeds, g.ToGeneticDistribution())
+	}
+	tests := append([]*Test{NoTest, MatchesParentTest(s, 0)}, PhenotypeTestsUpToSize(s, 1)...)
+	keepPred := func(flower.GeneticDistribution) bool { return true }
+
+	want := NewGraph(tests, seeds)
+	want.Expand(keepPred)
+	want.Expand(keepPred)
+
+	ws := startTestWorkers(t, 2, "serve")
+	got := NewGraph(tests, seeds)
+	for i := 0; i < 2; i++ {
+		if err := got.ExpandWith(ws, keepPred); err != nil {
+			t.Fatalf("ExpandWith (step %d) failed: %v", i+1, err)
+		}
+	}
+	if err := ws.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if describeGraph(got) != describeGraph(want) {
+		t.Errorf("Graph expanded with workers differs from graph expanded with Expand")
+	}
+}
+
+func TestExpandWithFailedWorker(t *testing.T) {
+	ws := startTestWorkers(t, 2, "fail")
+	g := roseGraph(0)
+	if err := g.ExpandWith(ws, func(flower.GeneticDistribution) bool { return true }); err == nil {
+		t.Errorf("ExpandWith with failing workers succeeded")
+	}
+	if err := g.ExpandWith(ws, func(flower.GeneticDistribution) bool { return true }); err == nil {
+		t.Errorf("ExpandWith after failure succeeded")
+	}
+	if err := ws.Close(); err == nil {
+		t.Errorf("Close of failed workers succeeded")
+	}
+}
+
+func TestExpandWithPartialFailure(t *testing.T) {
+	g := roseGraph(1)
+	n := len(g.verts)
+	ws := startTestWorkers(t, 2, "partial")
+	defer ws.Close()
+	if err := g.ExpandWith(ws, func(flower.GeneticDistribution) bool { return true }); err == nil {
+		t.Fatalf("ExpandWith with partially-failing workers succeeded")
+	}
+
+	// The new vertices should have been removed.
+	if len(g.verts) != n {
+		t.Errorf("After failed expansion, graph has %d vertices, want %d", len(g.verts), n)
+	}
+	for _, v := range g.verts {
+		if got := g.vertIndex.get(v.canonical); got != v {
+			t.Errorf("After failed expansion, vertex %d is indexed as %p, want %p", v.id, got, v)
+		}
+		for _, c := range v.children {
+			if c.id >= n {
+				t.Errorf("After failed expansion, vertex %d has removed child %d", v.id, c.id)
+			}
+		}
+	}
+
+	// Expanding again should complete the expansion.
+	g.Expand(func(flower.GeneticDistribution) bool { return true })
+	want := roseGraph(2)
+	if got, want := len(g.verts), len(want.verts); got != want {
+		t.Errorf("After expanding again, graph has %d vertices, want %d", got, want)
+	}
+	for _, v := range want.verts {
+		w := g.vertIndex.get(v.canonical)
+		if w == nil {
+			t.Errorf("After expanding again, graph is missing %v", v.dist.sparse())
+			continue
+		}
+		if got, want := w.pathCost(), v.pathCost(); math.Abs(got-want) > 1e-9 {
+			t.Errorf("After expanding again, %v has path cost %v, want %v", v.dist.sparse(), got, want)
+		}
+	}
+}
+
+func TestPathStatsCache(t *testing.T) {
+	g := roseGraph(1)
+	// Populate caches, then expand further, updating some predecessors.
+	g.Search(func(flower.GeneticDistribution) bool { return true })
+	g.Expand(func(flower.GeneticDistribution) bool { return true })
+
+	for _, v := range g.verts {
+		// Compute stats from scratch.
+		want := PathStats{Steps: stepsTo(v)}
+		if v.pred != nil {
+			want.Priority = v.pred.test.Priority()
+		}
+		visitSubgraphPathingToAllOf([]interface{}{v}, func(x interface{}) {
+			switch x := x.(type) {
+			case *vertex:
+				want.Flowers++
+			case *edge:
+				want.Cost += x.cost
+				if x.test != NoTest {
+					want.Tests++
+				}
+			}
+		})
+
+		if got := v.pathStats(); got.Steps != want.Steps || got.Tests != want.Tests || got.Flowers != want.Flowers || got.Priority != want.Priority || math.Abs(got.Cost-want.Cost) > 1e-9 {
+			t.Errorf("Vertex %d has cached stats %+v, want %+v", v.id, got, want)
+		}
+	}
+}
+
+// stepsTo returns the number of generations on the path to v.
+func stepsTo(v *vertex) int {
+	if v.pred == nil {
+		return 0
+	}
+	s0, s1 := stepsTo(v.pred.pred[0]), stepsTo(v.pred.pred[1])
+	if s1 > s0 {
+		return s1 + 1
+	}
+	return s0 + 1
+}
+
+func TestRelax(t *testing.T) {
+	roses := flower.Roses()
+	parse := func(str string) flower.GeneticDistribution {
+		gd, err := roses.ParseGeneticDistribution(str)
+		if err != nil {
+			t.Fatalf("Couldn't parse genetic distribution %q: %v", str, err)
+		}
+		return gd
+	}
+	red, yellow, orange := parse("RRyyWWSs"), parse("rrYYWWss"), parse("RrYyWWss")
+	orangeTest, redTest := PhenotypeTest(roses, flower.Orange), PhenotypeTest(roses, flower.Red)
+	g := NewGraph([]*Test{NoTest, orangeTest, redTest}, []flower.GeneticDistribution{red, yellow})
+	vRed, vYellow := g.verts[0], g.verts[1]
+	addVertex := func(gd flower.GeneticDistribution, e *edge) *vertex {
+		k := newDistKey(gd.ToSparse())
+		v := g.addVertex(k, k)
+		v.setPred(e)
+		return v
+	}
+
+	// Craft a graph where an orange rose is overpriced, as are the red roses
+	// bred from it. Two other flowers, which could be derived from the orange
+	// & red roses, are instead given (fictional) cheaper derivations directly
+	// from the seeds.
+	vOrange := addVertex(orange, &edge{pred: [2]*vertex{vRed, vYellow}, test: orangeTest, cost: 100})
+	redF2, redF2Cost := redTest.TestBred([2]flower.GeneticDistribution{orange, orange}, orange.Breed(orange))
+	vRedF2 := addVertex(redF2, &edge{pred: [2]*vertex{vOrange, vOrange}, test: redTest, cost: redF2Cost})
+	vOrangeYellow := addVertex(orange.Breed(yellow), &edge{pred: [2]*vertex{vRed, vYellow}, test: NoTest, cost: 50})
+	vRedF2Yellow := addVertex(redF2.Breed(yellow), &edge{pred: [2]*vertex{vRed, vYellow}, test: NoTest, cost: 50})
+
+	// Fix the orange rose's cost & relax the graph: both flowers should now be
+	// derived from the orange rose, directly or via the red roses.
+	vOrange.setPred(&edge{pred: [2]*vertex{vRed, vYellow}, test: orangeTest, cost: 2})
+	g.relax([]improvement{{vOrange, len(g.verts)}}, g.distributions(len(g.verts)))
+	for _, test := range []struct {
+		desc        string
+		v           *vertex
+		wantParents [2]*vertex
+		wantCost    float64
+	}{
+		{"orange x yellow", vOrangeYellow, [2]*vertex{vYellow, vOrange}, 3},
+		{"red F2 x yellow", vRedF2Yellow, [2]*vertex{vYellow, vRedF2}, 3 + redF2Cost},
+	} {
+		if got := test.v.pred.pred; got != test.wantParents {
+			t.Errorf("After relaxation, %s has parents %d & %d, want %d & %d", test.desc, got[0].id, got[1].id, test.wantParents[0].id, test.wantParents[1].id)
+		}
+		if got := test.v.pathCost(); got != test.wantCost {
+			t.Errorf("After relaxation, %s has path cost %v, want %v", test.desc, got, test.wantCost)
+		}
+	}
+}
+
+func TestRelaxPolicies(t *testing.T) {
+	roses := flower.Roses()
+	parse := func(str string) flower.GeneticDistribution {
+		gd, err := roses.ParseGeneticDistribution(str)
+		if err != nil {
+			t.Fatalf("Couldn't parse genetic distribution %q: %v", str, err)
+		}
+		return gd
+	}
+	red, yellow, orange := parse("RRyyWWSs"), parse("rrYYWWss"), parse("RrYyWWss")
+	orangeTest, redTest := PhenotypeTest(roses, flower.Orange), PhenotypeTest(roses, flower.Red)
+	mostFlowers := NewPolicy("most-flowers", "", func(a, b PathStats) bool { return a.Flowers > b.Flowers })
+
+	for _, p := range append(Policies(), mostFlowers) {
+		t.Run(p.Name(), func(t *testing.T) {
+			g := NewGraph([]*Test{NoTest, orangeTest, redTest}, []flower.GeneticDistribution{red, yellow})
+			g.SetPolicy(p)
+			vRed, vYellow := g.verts[0], g.verts[1]
+			addVertex := func(gd flower.GeneticDistribution, e *edge) *vertex {
+				k := newDistKey(gd.ToSparse())
+				v := g.addVertex(k, k)
+				v.setPred(e)
+				return v
+			}
+
+			// Craft a graph where the children of orange roses & yellow seeds
+			// are given a (fictional) derivation from red roses bred from the
+			// orange roses, which is worse by every built-in policy than
+			// breeding them directly: it is more costly, & needs more steps,
+			// tests & flowers.
+			vOrange := addVertex(orange, &edge{pred: [2]*vertex{vRed, vYellow}, test: orangeTest, cost: 100})
+			redF2, redF2Cost := redTest.TestBred([2]flower.GeneticDistribution{orange, orange}, orange.Breed(orange))
+			vRedF2 := addVertex(redF2, &edge{pred: [2]*vertex{vOrange, vOrange}, test: redTest, cost: redF2Cost})
+			vOrangeYellow := addVertex(orange.Breed(yellow), &edge{pred: [2]*vertex{vRedF2, vRedF2}, test: redTest, cost: 1})
+
+			vOrange.setPred(&edge{pred: [2]*vertex{vRed, vYellow}, test: orangeTest, cost: 2})
+			g.relax([]improvement{{vOrange, len(g.verts)}}, g.distributions(len(g.verts)))
+
+			wantParents := [2]*vertex{vYellow, vOrange}
+			if p == mostFlowers {
+				wantParents = [2]*vertex{vRedF2, vRedF2}
+			}
+			if got := vOrangeYellow.pred.pred; got != wantParents {
+				t.Errorf("After relaxation, orange x yellow has parents %d & %d, want %d & %d", got[0].id, got[1].id, wantParents[0].id, wantParents[1].id)
+			}
+
+			// No breeding of the graph's vertices should improve any vertex.
+			gds := g.distributions(len(g.verts))
+			for i, va := range g.verts {
+				for _, vb := range g.verts[i:] {
+					parents := [2]flower.SparseGeneticDistribution{gds[va.id], gds[vb.id]}
+					for _, test := range g.tests {
+						gd, cost := test.testBred(parents, parents[0].Breed(parents[1]))
+						if gd.IsZero() {
+							continue
+						}
+						_, canonical := g.keys(gd, gd.ToGeneticDistribution())
+						e := &edge{pred: [2]*vertex{va, vb}, test: test, cost: cost}
+						if w := g.vertIndex.get(canonical); w != nil && p.less(e.pathStats(), w.pathStats()) && !e.dependsOn(w) {
+							t.Errorf("After relaxation, breeding %d & %d with test %q improves vertex %d", va.id, vb.id, test.Name(), w.id)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRelaxRejectsNonStrictPolicy(t *testing.T) {
+	g := roseGraph(1)
+	g.SetPolicy(NewPolicy("reflexive", "", func(a, b PathStats) bool { return a.Cost <= b.Cost }))
+	defer func() {
+		if recover() == nil {
+			t.Errorf("relax with non-strict policy did not panic")
+		}
+	}()
+	g.relax([]improvement{{g.verts[0], len(g.verts)}}, g.distributions(len(g.verts)))
+}
+
+func TestNoCycles(t *testing.T) {
+	// A policy preferring longer paths would happily create cycles, were they
+	// allowed.
+	g := roseGraph(0)
+	g.SetPolicy(NewPolicy("most-flowers", "", func(a, b PathStats) bool { return a.Flowers > b.Flowers }))
+	g.Expand(func(flower.GeneticDistribution) bool { return true })
+	g.Expand(func(flower.GeneticDistribution) bool { return true })
+
+	for _, v := range g.verts {
+		if v.pred == nil {
+			continue
+		}
+		visitSubgraphPathingToAllOf([]interface{}{v.pred.pred[0], v.pred.pred[1]}, func(x interface{}) {
+			if x == v {
+				t.Fatalf("Vertex %d is its own ancestor", v.id)
+			}
+		})
+	}
+}
+
+func TestEquivalence(t *testing.T) {
+	roses := flower.Roses()
+	var seeds []flower.GeneticDistribution
+	for _, g := range roses.Seeds() {
+		seeds = append(seeds, g.ToGeneticDistribution())
+	}
+	eq := GeneEquivalence(0, 1)
+	expand := func(eq *Equivalence) *Graph {
+		// Without tests, the graph may project away any genes.
+		g := NewGraph([]*Test{NoTest}, seeds)
+		if err := g.SetEquivalence(eq); err != nil {
+			t.Fatalf("SetEquivalence(%s) got unexpected error: %v", eq.Name(), err)
+		}
+		g.Expand(func(flower.GeneticDistribution) bool { return true })
+		g.Expand(func(flower.GeneticDistribution) bool { return true })
+		return g
+	}
+	g, projected := expand(IdentityEquivalence), expand(eq)
+	if len(projected.verts) >= len(g.verts) {
+		t.Errorf("Projected graph has %d vertices, want fewer than %d", len(projected.verts), len(g.verts))
+	}
+
+	// Each class of equivalent distributions should be a single vertex, as
+	// cheap as the cheapest member of the class in the unprojected graph.
+	projectedCost := map[flower.GeneticDistribution]float64{}
+	for _, v := range projected.verts {
+		k := eq.Canonical(Vertex{v}.Value())
+		if _, ok := projectedCost[k]; ok {
+			t.Errorf("Projected graph has multiple vertices equivalent to %s", roses.RenderGeneticDistribution(k))
+		}
+		projectedCost[k] = v.pathCost()
+	}
+	wantCost := map[flower.GeneticDistribution]float64{}
+	for _, v := range g.verts {
+		k := eq.Canonical(Vertex{v}.Value())
+		if c, ok := wantCost[k]; !ok || v.pathCost() < c {
+			wantCost[k] = v.pathCost()
+		}
+	}
+	for k, want := range wantCost {
+		if got, ok := projectedCost[k]; !ok || got != want {
+			t.Errorf("Projected graph cost for %s = %v (ok = %v), want %v", roses.RenderGeneticDistribution(k), got, ok, want)
+		}
+	}
+}
+
+func TestEquivalentPredecessorSwap(t *testing.T) {
+	roses := flower.Roses()
+	var seeds []flower.GeneticDistribution
+	for _, str := range []string{"RRyyWWSs", "rrYYWWss", "rryyWwss"} {
+		gd, err := roses.ParseGeneticDistribution(str)
+		if err != nil {
+			t.Fatalf("Couldn't parse genetic distribution %q: %v", str, err)
+		}
+		seeds = append(seeds, gd)
+	}
+	g := NewGraph([]*Test{NoTest}, seeds)
+	if err := g.SetEquivalence(GeneEquivalence(0)); err != nil {
+		t.Fatalf("SetEquivalence got unexpected error: %v", err)
+	}
+	red, yellow, white := g.verts[0], g.verts[1], g.verts[2]
+	add := func(p0, p1 *vertex, cost float64) *vertex {
+		gd := p0.dist.sparse().Breed(p1.dist.sparse())
+		dist, canonical := g.keys(gd, gd.ToGeneticDistribution())
+		g.add(&edge{pred: [2]*vertex{p0, p1}, test: NoTest, cost: cost}, dist, canonical, true)
+		return g.vertIndex.get(canonical)
+	}
+
+	// Red & yellow roses produce Rr children, as do red & white roses, though
+	// their other genes differ. Once the first such child has been bred, a
+	// cheaper path to the second replaces its best predecessor.
+	v := add(red, yellow, 5)
+	w := add(v, red, 1)
+	if got := add(red, white, 1); got != v || v.pred.pred[1] != white || !v.inexact {
+		t.Fatalf("Cheaper equivalent flower didn't replace best predecessor")
+	}
+
+	// Values along the path to each vertex must be those produced by breeding
+	// the values of its parents.
+	for _, v := range []*vertex{v, w} {
+		e, _ := Vertex{v}.BestPredecessor()
+		want := e.FirstParent().Value().Breed(e.SecondParent().Value())
+		if got := e.Child().Value(); got != want {
+			t.Errorf("Vertex value %s differs from %s, bred from its parents' values", roses.RenderGeneticDistribution(got), roses.RenderGeneticDistribution(want))
+		}
+	}
+}
+
+func TestEquivalenceWithPhenotypeTests(t *testing.T) {
+	// Purple hyacinths depend only on the first two genes, unlike white ones.
+	s := flower.Hyacinths()
+	var initial []flower.GeneticDistribution
+	for _, str := range []string{"RRyyWW", "rrYYWW", "rrYYww"} {
+		gd, err := s.ParseGeneticDistribution(str)
+		if err != nil {
+			t.Fatalf("Couldn't parse genetic distribution %q: %v", str, err)
+		}
+		initial = append(initial, gd)
+	}
+	eq := GeneEquivalence(0, 1)
+	if err := NewGraph([]*Test{NoTest, PhenotypeTest(s, flower.White)}, initial).SetEquivalence(eq); err == nil {
+		t.Errorf("SetEquivalence with white test got no error")
+	}
+	if err := NewGraph([]*Test{NoTest, Not(MatchesParentTest(s, 0))}, initial).SetEquivalence(eq); err == nil {
+		t.Errorf("SetEquivalence with parent test got no error")
+	}
+
+	purpleTest := PhenotypeTest(s, flower.Purple)
+	g := NewGraph([]*Test{NoTest, purpleTest}, initial)
+	if err := g.SetEquivalence(eq); err != nil {
+		t.Fatalf("SetEquivalence with purple test got unexpected error: %v", err)
+	}
+	red, yellow, yellowWW := g.verts[0], g.verts[1], g.verts[2]
+	add := func(p0, p1 *vertex, test *Test, cost float64) *vertex {
+		gd, _ := test.testBred([2]flower.SparseGeneticDistribution{p0.dist.sparse(), p1.dist.sparse()}, p0.dist.sparse().Breed(p1.dist.sparse()))
+		dist, canonical := g.keys(gd, gd.ToGeneticDistribution())
+		g.add(&edge{pred: [2]*vertex{p0, p1}, test: test, cost: cost}, dist, canonical, true)
+		return g.vertIndex.get(canonical)
+	}
+
+	// Both yellow hyacinths produce RrYy children with red ones, so a cheaper
+	// path from the second replaces the first, making the children inexact.
+	// Purple hyacinths bred from those children are still found at the cost of
+	// breeding the children's value.
+	v := add(red, yellow, NoTest, 5)
+	add(red, yellowWW, NoTest, 1)
+	_, purpleCost := purpleTest.TestBred([2]flower.GeneticDistribution{}, v.dist.dense().Breed(v.dist.dense()))
+	add(v, v, purpleTest, purpleCost)
+	if !v.inexact {
+		t.Fatalf("Cheaper equivalent flower didn't make vertex inexact")
+	}
+
+	// Costs along each path must match those of breeding the path's values.
+	g.VisitVertices(func(v Vertex) {
+		e, ok := v.BestPredecessor()
+		if !ok {
+			return
+		}
+		parents := [2]flower.GeneticDistribution{e.FirstParent().Value(), e.SecondParent().Value()}
+		gd, cost := e.Test().TestBred(parents, parents[0].Breed(parents[1]))
+		if gd != v.Value() || cost != e.EdgeCost() {
+			t.Errorf("Breeding parents' values with test %q gives %s at cost %v, want %s at cost %v", e.Test().Name(), s.RenderGeneticDistribution(gd), cost, s.RenderGeneticDistribution(v.Value()), e.EdgeCost())
+		}
+	})
+}
+
+func TestDistKey(t *testing.T) {
+	roses := flower.Roses()
+	g := roseGraph(1)
+	gds := append(g.distributions(len(g.verts)), flower.SparseGeneticDistribution{})
+	for i, gda := range gds {
+		ka := newDistKey(gda)
+		if !ka.sparse().Equal(gda) || ka.dense() != gda.ToGeneticDistribution() {
+			t.Errorf("Key for %s decoded to %s", roses.RenderGeneticDistribution(gda.ToGeneticDistribution()), roses.RenderGeneticDistribution(ka.dense()))
+		}
+		for j, gdb := range gds {
+			// Rebuild the distribution, so that it shares no memory.
+			kb := newDistKey(gdb.ToGeneticDistribution().ToSparse())
+			if eq := ka.hash == kb.hash && ka.enc == kb.enc; eq != (i == j) {
+				t.Errorf("Keys for %s & %s equal = %v, want %v", roses.RenderGeneticDistribution(gda.ToGeneticDistribution()), roses.RenderGeneticDistribution(gdb.ToGeneticDistribution()), eq, i == j)
+			}
+		}
+	}
+
+	// Vertices whose keys share a hash should be distinguished.
+	vi := vertexIndex{}
+	ka, kb := newDistKey(gds[0]), newDistKey(gds[1])
+	kb.hash = ka.hash
+	va, vb := &vertex{canonical: ka}, &vertex{canonical: kb}
+	vi.put(va)
+	vi.put(vb)
+	if got := vi.get(ka); got != va {
+		t.Errorf("get(ka) = %p, want %p", got, va)
+	}
+	if got := vi.get(kb); got != vb {
+		t.Errorf("get(kb) = %p, want %p", got, vb)
+	}
+	if got := vi.get(newDistKey(gds[2])); got != nil {
+		t.Errorf("get(absent key) = %p, want nil", got)
+	}
+}
+
+func BenchmarkExpand(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		roseGraph(2)
+	}
+}
+
+// BenchmarkExpandExisting benchmarks a third expansion keeping no new vertices,
+// which is dominated by comparing new edges against existing vertices.
+func BenchmarkExpandExisting(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		g := roseGraph(2)
+		b.StartTimer()
+		g.Expand(func(flower.GeneticDistribution) bool { return false })
+	}
+}
+
+// BenchmarkExpandPolicies benchmarks three expansions under each policy,
+// reporting the number of breedings including those re-done while relaxing.
+func BenchmarkExpandPolicies(b *testing.B) {
+	for _, p := range Policies() {
+		b.Run(p.Name(), func(b *testing.B) {
+			var breedings int
+			for i := 0; i < b.N; i++ {
+				g := roseGraph(0)
+				g.SetPolicy(p)
+				g.Expand(func(flower.GeneticDistribution) bool { return true })
+				g.Expand(func(flower.GeneticDistribution) bool { return true })
+				g.Expand(func(flower.GeneticDistribution) bool { return false })
+				breedings = g.breedings
+			}
+			b.ReportMetric(float64(breedings), "breedings")
+		})
+	}
+}
+
+// BenchmarkPlan compares planning a path to RRYYwwss roses, which can't be
+// bred from seeds in fewer than three steps, against expanding the graph three
+// times, reporting the number of breedings.
+func BenchmarkPlan(b *testing.B) {
+	roses := flower.Roses()
+	target, err := roses.ParseGenotype("RRYYwwss")
+	if err != nil {
+		b.Fatalf("Couldn't parse genotype: %v", err)
+	}
+	isTarget := func(gd flower.GeneticDistribution) bool { return gd == target.ToGeneticDistribution() }
+
+	b.Run("expand", func(b *testing.B) {
+		var breedings int
+		for i := 0; i < b.N; i++ {
+			g := roseGraph(2)
+			g.Expand(isTarget)
+			if _, ok := g.Search(isTarget); !ok {
+				b.Fatalf("Search found no path to RRYYwwss")
+			}
+			breedings = g.breedings
+		}
+		b.ReportMetric(float64(breedings), "breedings")
+	})
+
+	b.Run("plan", func(b *testing.B) {
+		var breedings int
+		for i := 0; i < b.N; i++ {
+			g := roseGraph(0)
+			if _, ok := g.Plan(roses, target, 3); !ok {
+				b.Fatalf("Plan found no path to RRYYwwss")
+			}
+			breedings = g.breedings
+		}
+		b.ReportMetric(float64(breedings), "breedings")
+	})
+}
+
+// BenchmarkBreedRow benchmarks the inner loop of expansion: breeding a vertex
+// with every other vertex & applying each test to the children.
+func BenchmarkBreedRow(b *testing.B) {
+	g := roseGraph(2)
+	gds := g.distributions(len(g.verts))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		breedRow(g.tests, gds, i%len(gds), 0, func(int, int, flower.SparseGeneticDistribution, float64) {})
+	}
+}
+
+// BenchmarkGraphMemory reports the memory retained by an expanded graph.
+func BenchmarkGraphMemory(b *testing.B) {
+	var g *Graph
+	var before, after runtime.MemStats
+	for i := 0; i < b.N; i++ {
+		g = nil
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		g = roseGraph(2)
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+	}
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(len(g.verts)), "B/vertex")
+	b.ReportMetric(float64(len(g.verts)), "vertices")
+}
+
+// BenchmarkVertexLookup compares looking up vertices by compact key against
+// looking them up by dense distribution.
+func BenchmarkVertexLookup(b *testing.B) {
+	g := roseGraph(2)
+	gds := g.distributions(len(g.verts))
+
+	b.Run("dense", func(b *testing.B) {
+		m := map[flower.GeneticDistribution]*vertex{}
+		dense := make([]flower.GeneticDistribution, len(gds))
+		for i, gd := range gds {
+			dense[i] = gd.ToGeneticDistribution()
+			m[dense[i]] = g.verts[i]
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if m[dense[i%len(dense)]] == nil {
+				b.Fatalf("Vertex not found")
+			}
+		}
+	})
+
+	b.Run("compact", func(b *testing.B) {
+		keys := make([]distKey, len(gds))
+		for i, gd := range gds {
+			keys[i] = newDistKey(gd)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if g.vertIndex.get(keys[i%len(keys)]) == nil {
+				b.Fatalf("Vertex not found")
+			}
+		}
+	})
+}
+
+func BenchmarkSearch(b *testing.B) {
+	g := roseGraph(2)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Search(func(flower.GeneticDistribution) bool { return true })
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/BranLwyd/acnh_flowers/breedgraph"
+)
+
+// expandWorkerCommand serves as a worker process for the plan command, which
+// starts such processes when given the -workers flag.
+func expandWorkerCommand(args []string) error {
+	fs := flag.NewFlagSet("expand-worker", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s expand-worker\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Reads graph expansion jobs from standard input, writing results to standard output.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return breedgraph.ServeExpandWorker(os.Stdin, os.Stdout)
+}
@@ -3,9 +3,9 @@
 package flower
 
 import (
-	"errors"
 	"fmt"
-	"regexp"
+	"math/bits"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -19,18 +19,68 @@ func Roses() Species       { return roses }
 func Tulips() Species      { return tulips }
 func Windflowers() Species { return windflowers }
 
+// AllSpecies returns all built-in species, ordered by name.
+func AllSpecies() []Species {
+	return []Species{cosmos, hyacinths, lilies, mums, pansies, roses, tulips, windflowers}
+}
+
+// SpeciesByName returns the built-in species with the given name, e.g.
+// "Roses". Names are matched case-insensitively.
+func SpeciesByName(name string) (_ Species, ok bool) {
+	for _, s := range AllSpecies() {
+		if strings.EqualFold(s.name, name) {
+			return s, true
+		}
+	}
+	return Species{}, false
+}
+
 // Species represents a specific species of flower, such as Windflower or Mum.
 type Species struct {
 	name       string        // a human-readable name for this species, e.g. "Windflowers".
 	phenotypes [81]Phenotype // phenotypes by genotype
+	seeds      []Genotype    // genotypes of the seed flowers available in shops
 	serde      GenotypeSerde // the (default) serializer/deserializer for genotypes; also determines gene count
+
+	phenotypeInfo map[Phenotype]PhenotypeInfo // species-specific names, aliases & colors of phenotypes, & phenotypes specific to this species
 }
 
-func newSpecies(name string, phenotypes map[string]string) (Species, error) {
-	s := Species{name: name}
+// SpeciesSpec defines a species. See NewSpecies.
+type SpeciesSpec struct {
+	Name       string            // a human-readable name for the species, e.g. "Windflowers"
+	Seeds      []string          // genotypes of the seed flowers available in shops
+	Phenotypes map[string]string // the phenotype of each genotype, e.g. "RrYyWw": "Red"; all 27 or 81 genotypes must be given
+
+	// Species-specific names, aliases & colors of phenotypes, & phenotypes
+	// specific to this species. See PhenotypeSpec.
+	PhenotypeSpecs []PhenotypeSpec
+}
+
+// PhenotypeSpec describes a phenotype as it applies to a species.
+//
+// If Builtin names a built-in phenotype, such as "Black", the entry describes
+// that phenotype for the species: its Name, if set, replaces the phenotype's
+// name; its Aliases extend the phenotype's; & its Color, if set, replaces the
+// phenotype's color. Otherwise, the entry adds a phenotype specific to the
+// species, which must have a Name & Color.
+type PhenotypeSpec struct {
+	Builtin string
+	PhenotypeInfo
+}
+
+// NewSpecies creates a species from its definition. Phenotypes are named as
+// accepted by ParsePhenotype, or by a species-specific name or alias.
+// Species-specific names & aliases must not name any other phenotype, ignoring
+// case.
+func NewSpecies(spec SpeciesSpec) (Species, error) {
+	s := Species{name: spec.Name}
+	if err := s.setPhenotypeInfo(spec.PhenotypeSpecs); err != nil {
+		return Species{}, err
+	}
+
 	gsInit := false
 	var gs GenotypeSerde
-	for gStr, pStr := range phenotypes {
+	for gStr, pStr := range spec.Phenotypes {
 		if !gsInit {
 			serde, err := NewGenotypeSerdeFromExample(gStr)
 			if err != nil {
@@ -43,48 +93,123 @@ func newSpecies(name string, phenotypes map[string]string) (Species, error) {
 		if err != nil {
 			return Species{}, err
 		}
-		p, err := ParsePhenotype(pStr)
+		p, err := s.lookupPhenotype(pStr)
 		if err != nil {
 			return Species{}, fmt.Errorf("genotype %q has unparseable phenotype: %v", gStr, err)
 		}
 		if s.phenotypes[genotypeToIdx[g]] != Unknown {
-			return Species{}, fmt.Errorf("genotype %q has multiple phenotypes (%q & %q)", gStr, s.phenotypes[genotypeToIdx[g]], p)
+			return Species{}, fmt.Errorf("genotype %q has multiple phenotypes (%q & %q)", gStr, s.RenderPhenotype(s.phenotypes[genotypeToIdx[g]]), s.RenderPhenotype(p))
 		}
 		s.phenotypes[genotypeToIdx[g]] = p
 	}
 	s.serde = gs
 
-	if gs.GeneCount() == 3 && len(phenotypes) != 27 {
-		return Species{}, fmt.Errorf("got %d phenotypes, expected 27", len(phenotypes))
+	if gs.GeneCount() == 3 && len(spec.Phenotypes) != 27 {
+		return Species{}, fmt.Errorf("got %d phenotypes, expected 27", len(spec.Phenotypes))
+	}
+	if gs.GeneCount() == 4 && len(spec.Phenotypes) != 81 {
+		return Species{}, fmt.Errorf("got %d phenotypes, expected 81", len(spec.Phenotypes))
+	}
+	for p := range s.phenotypeInfo {
+		if !s.hasPhenotype(p) {
+			return Species{}, fmt.Errorf("got phenotype info for %s, which %s don't have", s.RenderPhenotype(p), s.name)
+		}
 	}
-	if gs.GeneCount() == 4 && len(phenotypes) != 81 {
-		return Species{}, fmt.Errorf("got %d phenotypes, expected 81", len(phenotypes))
+
+	for _, gStr := range spec.Seeds {
+		g, err := gs.ParseGenotype(gStr)
+		if err != nil {
+			return Species{}, fmt.Errorf("couldn't parse seed genotype: %v", err)
+		}
+		s.seeds = append(s.seeds, g)
 	}
 
 	return s, nil
 }
 
-func mustSpecies(name string, phenotypes map[string]string) Species {
-	s, err := newSpecies(name, phenotypes)
+func mustSpecies(name string, seeds []string, phenotypes map[string]string, specs ...PhenotypeSpec) Species {
+	s, err := NewSpecies(SpeciesSpec{name, seeds, phenotypes, specs})
 	if err != nil {
 		panic(fmt.Sprintf("Could not create species %q: %v", name, err))
 	}
 	return s
 }
 
+// Spec returns the definition of this species, from which NewSpecies would
+// create an identical species.
+func (s Species) Spec() SpeciesSpec {
+	rslt := SpeciesSpec{Name: s.name, Phenotypes: map[string]string{}}
+	for _, g := range s.seeds {
+		rslt.Seeds = append(rslt.Seeds, s.RenderGenotype(g))
+	}
+	for _, g := range s.Genotypes() {
+		rslt.Phenotypes[s.RenderGenotype(g)] = s.RenderPhenotype(s.Phenotype(g))
+	}
+	for _, p := range s.Phenotypes() {
+		info, ok := s.phenotypeInfo[p]
+		if !ok {
+			continue
+		}
+		var builtin string
+		if p < firstSpeciesPhenotype {
+			builtin = p.String()
+		}
+		rslt.PhenotypeSpecs = append(rslt.PhenotypeSpecs, PhenotypeSpec{builtin, PhenotypeInfo{info.Name, append([]string(nil), info.Aliases...), info.Color}})
+	}
+	return rslt
+}
+
 func (s Species) Name() string                   { return s.name }
 func (s Species) GeneCount() int                 { return s.serde.GeneCount() }
 func (s Species) Phenotype(g Genotype) Phenotype { return s.phenotypes[genotypeToIdx[g]] }
 
+// Seeds returns the genotypes of the seed flowers of this species, which are
+// available for purchase.
+func (s Species) Seeds() []Genotype { return append([]Genotype(nil), s.seeds...) }
+
+// Genotypes returns all genotypes of this species, in canonical order.
+func (s Species) Genotypes() []Genotype {
+	rslt := make([]Genotype, 0, 81)
+	for _, g := range idxToGenotype {
+		if s.GeneCount() == 3 && g.gene3() != 0 {
+			continue
+		}
+		rslt = append(rslt, g)
+	}
+	return rslt
+}
+
+// Phenotypes returns all phenotypes of this species, in phenotype order.
 func (s Species) Phenotypes() []Phenotype {
 	rsltMap := map[Phenotype]struct{}{}
-	for _, p := range s.phenotypes {
-		rsltMap[p] = struct{}{}
+	for _, g := range s.Genotypes() {
+		rsltMap[s.Phenotype(g)] = struct{}{}
 	}
 	var rslt []Phenotype
 	for p := range rsltMap {
 		rslt = append(rslt, p)
 	}
+	sort.Slice(rslt, func(i, j int) bool { return rslt[i] < rslt[j] })
+	return rslt
+}
+
+// RelevantGenes returns the genes (numbered from 0, in the order they are
+// written) that pred depends on, for genotypes of this species.
+func (s Species) RelevantGenes(pred func(Genotype) bool) []int {
+	gs := s.Genotypes()
+	var rslt []int
+	for i := 0; i < s.GeneCount(); i++ {
+		shift := uint(2 * i)
+	genotypes:
+		for _, g := range gs {
+			for allele := Genotype(0); allele < 3; allele++ {
+				if pred(g&^(0b11<<shift)|allele<<shift) != pred(g) {
+					rslt = append(rslt, i)
+					break genotypes
+				}
+			}
+		}
+	}
 	return rslt
 }
 
@@ -92,74 +217,20 @@ func (s Species) ParseGenotype(genotype string) (Genotype, error) {
 	return s.serde.ParseGenotype(genotype)
 }
 func (s Species) RenderGenotype(g Genotype) string { return s.serde.RenderGenotype(g) }
-func (s Species) RenderGeneticDistribution(gd GeneticDistribution) string {
-	return s.serde.RenderGeneticDistribution(gd)
-}
 
-// Phenotype represents a specific outward appearance for a species, e.g. Red or Yellow.
-type Phenotype uint8
-
-const (
-	Unknown Phenotype = iota
-	White
-	Pink
-	Red
-	Orange
-	Yellow
-	Green
-	Blue
-	Purple
-	Black
-)
+// ParseGeneticDistribution parses a genetic distribution, such as
+// "{1:RrYyWwSs, 3:RRYyWwSs}". Each term's genotype may use wildcards (e.g.
+// "RrYy??ss" or "rryyW_ss") & alternatives (e.g. "(Rr|RR)yyWWss"), & its odds
+// may be written as a percentage or fraction (e.g. "RrYy??ss 50%" or
+// "1/4:RrYyWwss"). A term may also be a shorthand such as "Orange from seeds".
+// Rendering the result gives the distribution in canonical form.
+func (s Species) ParseGeneticDistribution(geneticDistribution string) (GeneticDistribution, error) {
+	gd, _, err := parseGeneticDistribution(&s, s.serde, geneticDistribution)
+	return gd, err
+}
 
-func ParsePhenotype(phenotype string) (Phenotype, error) {
-	switch phenotype {
-	case "White":
-		return White, nil
-	case "Pink":
-		return Pink, nil
-	case "Red":
-		return Red, nil
-	case "Orange":
-		return Orange, nil
-	case "Yellow":
-		return Yellow, nil
-	case "Green":
-		return Green, nil
-	case "Blue":
-		return Blue, nil
-	case "Purple":
-		return Purple, nil
-	case "Black":
-		return Black, nil
-	default:
-		return Unknown, fmt.Errorf("unknown phenotype %q", phenotype)
-	}
-}
-
-func (p Phenotype) String() string {
-	switch p {
-	case White:
-		return "White"
-	case Pink:
-		return "Pink"
-	case Red:
-		return "Red"
-	case Orange:
-		return "Orange"
-	case Yellow:
-		return "Yellow"
-	case Green:
-		return "Green"
-	case Blue:
-		return "Blue"
-	case Purple:
-		return "Purple"
-	case Black:
-		return "Black"
-	default:
-		return fmt.Sprintf("[unknown phenotype ID %d]", p)
-	}
+func (s Species) RenderGeneticDistribution(gd GeneticDistribution) string {
+	return s.serde.RenderGeneticDistribution(gd)
 }
 
 // Genotype represents a specific set of genes for a species, e.g. RrwwYY.
@@ -195,6 +266,9 @@ func NewGenotypeSerdeFromExample(genotype string) (GenotypeSerde, error) {
 	}
 
 	genesFrom := func(gene string) ([3]string, error) {
+		if !isLetter(gene[0]) {
+			return [3]string{}, fmt.Errorf("could not parse gene %q: not an ASCII letter", gene)
+		}
 		lo, hi := strings.ToLower(gene[0:1]), strings.ToUpper(gene[0:1])
 		genes := [3]string{lo + lo, hi + lo, hi + hi}
 		if gene != genes[0] && gene != genes[1] && gene != genes[2] {
@@ -231,7 +305,7 @@ func NewGenotypeSerdeFromExample(genotype string) (GenotypeSerde, error) {
 }
 
 func NewGenotypeSerdeFromExampleDistribution(geneticDistribution string) (GenotypeSerde, error) {
-	_, gs, err := parseGeneticDistribution(GenotypeSerde{}, geneticDistribution)
+	_, gs, err := parseGeneticDistribution(nil, GenotypeSerde{}, geneticDistribution)
 	return gs, err
 }
 
@@ -292,84 +366,14 @@ func (gs GenotypeSerde) RenderGenotype(g Genotype) string {
 	return fmt.Sprintf("%s%s%s%s", gs.gene0[g.gene0()], gs.gene1[g.gene1()], gs.gene2[g.gene2()], gs.gene3[g.gene3()])
 }
 
+// ParseGeneticDistribution parses a genetic distribution; see
+// Species.ParseGeneticDistribution for the syntax. Shorthands referring to
+// phenotypes must name a species.
 func (gs GenotypeSerde) ParseGeneticDistribution(geneticDistribution string) (GeneticDistribution, error) {
-	gd, _, err := parseGeneticDistribution(gs, geneticDistribution)
+	gd, _, err := parseGeneticDistribution(nil, gs, geneticDistribution)
 	return gd, err
 }
 
-var genotypeRe = regexp.MustCompile(`^\w{6}(\w{2})?$`)
-
-func parseGeneticDistribution(gs GenotypeSerde, geneticDistribution string) (GeneticDistribution, GenotypeSerde, error) {
-	maybeCreateGS := func(geneticDistribution string) error {
-		if !gs.IsZero() {
-			return nil
-		}
-		newGS, err := NewGenotypeSerdeFromExample(geneticDistribution)
-		if err != nil {
-			return err
-		}
-		gs = newGS
-		return nil
-	}
-
-	if genotypeRe.MatchString(geneticDistribution) {
-		if err := maybeCreateGS(geneticDistribution); err != nil {
-			return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("couldn't parse genotype as genetic distribution: %v", err)
-		}
-		gd, err := gs.ParseGenotype(geneticDistribution)
-		if err != nil {
-			return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("couldn't parse genotype as genetic distribution: %v", err)
-		}
-		return gd.ToGeneticDistribution(), gs, nil
-	}
-
-	if len(geneticDistribution) == 0 || geneticDistribution[0] != '{' || geneticDistribution[len(geneticDistribution)-1] != '}' {
-		return GeneticDistribution{}, GenotypeSerde{}, errors.New("couldn't parse genetic distribution: not wrapped in curly quotes")
-	}
-	geneticDistribution = geneticDistribution[1 : len(geneticDistribution)-1]
-
-	var updErr error
-	rslt := GeneticDistribution{}.Update(func(gd *MutableGeneticDistribution) {
-		for _, term := range strings.Split(geneticDistribution, ",") {
-			term = strings.TrimSpace(term)
-			termSpl := strings.SplitN(term, ":", 2)
-			if len(termSpl) != 2 {
-				updErr = fmt.Errorf("couldn't parse genetic distribution: unparseable term %q", term)
-				return
-			}
-
-			odds, err := strconv.ParseUint(strings.TrimSpace(termSpl[0]), 10, 64)
-			if err != nil {
-				updErr = fmt.Errorf("couldn't parse genetic distribution: couldn't parse odds for term %q: %v", term, err)
-				return
-			}
-			if odds == 0 {
-				updErr = fmt.Errorf("couldn't parse genetic distribution: couldn't parse odds for term %q: odds are zero", term)
-				return
-			}
-
-			if err := maybeCreateGS(termSpl[1]); err != nil {
-				updErr = fmt.Errorf("couldn't parse genetic distribution: %v", err)
-				return
-			}
-			g, err := gs.ParseGenotype(strings.TrimSpace(termSpl[1]))
-			if err != nil {
-				updErr = fmt.Errorf("couldn't parse genetic distribution: couldn't parse genotype for term %q: %v", term, err)
-				return
-			}
-			if gd.GetOdds(g) != 0 {
-				updErr = fmt.Errorf("couldn't parse genetic distribution: duplicate genotype %q", gs.RenderGenotype(g))
-				return
-			}
-			gd.SetOdds(g, odds)
-		}
-	})
-	if updErr != nil {
-		return GeneticDistribution{}, GenotypeSerde{}, updErr
-	}
-	return rslt, gs, nil
-}
-
 func (gs GenotypeSerde) RenderGeneticDistribution(gd GeneticDistribution) string {
 	var sb strings.Builder
 	written := false
@@ -395,7 +399,7 @@ var zeroDist [81]uint64
 
 func (gd GeneticDistribution) IsZero() bool { return gd.dist == zeroDist }
 
-func (gd GeneticDistribution) GetOdds(g Genotype) uint64 { return gd.dist[g] }
+func (gd GeneticDistribution) GetOdds(g Genotype) uint64 { return gd.dist[genotypeToIdx[g]] }
 
 func (gd GeneticDistribution) Update(f func(*MutableGeneticDistribution)) GeneticDistribution {
 	mgd := &MutableGeneticDistribution{gd.dist}
@@ -417,6 +421,7 @@ func (gd GeneticDistribution) Visit(f func(_ Genotype, odds uint64) bool) {
 
 func (gda GeneticDistribution) Breed(gdb GeneticDistribution) GeneticDistribution {
 	var rslt GeneticDistribution
+	var touched [2]uint64
 
 	// Breed each pair of possible genotypes into the result.
 	for ga, pa := range gda.dist {
@@ -428,33 +433,71 @@ func (gda GeneticDistribution) Breed(gdb GeneticDistribution) GeneticDistributio
 			if pb == 0 {
 				continue
 			}
-			gb := Genotype(idxToGenotype[gb])
-
-			wt0 := punnetSquareLookupTable[ga.gene0()][gb.gene0()]
-			wt1 := punnetSquareLookupTable[ga.gene1()][gb.gene1()]
-			wt2 := punnetSquareLookupTable[ga.gene2()][gb.gene2()]
-			wt3 := punnetSquareLookupTable[ga.gene3()][gb.gene3()]
-
-			wt := pa * pb
-			for g0, w0 := range wt0 {
-				g := g0
-				wt := wt * w0
-				for g1, w1 := range wt1 {
-					g := g | (g1 << 2)
-					wt := wt * w1
-					for g2, w2 := range wt2 {
-						g := g | (g2 << 4)
-						wt := wt * w2
-						for g3, w3 := range wt3 {
-							g := g | (g3 << 6)
-							wt := wt * w3
-							rslt.dist[genotypeToIdx[g]] += wt
-						}
+			breedInto(&rslt.dist, &touched, ga, Genotype(idxToGenotype[gb]), pa*pb)
+		}
+	}
+	reduce(&rslt.dist)
+	return rslt
+}
+
+// breedInto adds the children of genotypes ga & gb to dist, weighted by wt, &
+// sets the bits of touched for the indices of the children.
+func breedInto(dist *[81]uint64, touched *[2]uint64, ga, gb Genotype, wt uint64) {
+	wt0 := punnetSquareLookupTable[ga.gene0()][gb.gene0()]
+	wt1 := punnetSquareLookupTable[ga.gene1()][gb.gene1()]
+	wt2 := punnetSquareLookupTable[ga.gene2()][gb.gene2()]
+	wt3 := punnetSquareLookupTable[ga.gene3()][gb.gene3()]
+
+	for g0, w0 := range wt0 {
+		if w0 == 0 {
+			continue
+		}
+		idx := 27 * g0
+		wt := wt * w0
+		for g1, w1 := range wt1 {
+			if w1 == 0 {
+				continue
+			}
+			idx := idx + 9*g1
+			wt := wt * w1
+			for g2, w2 := range wt2 {
+				if w2 == 0 {
+					continue
+				}
+				idx := idx + 3*g2
+				wt := wt * w2
+				for g3, w3 := range wt3 {
+					if w3 == 0 {
+						continue
 					}
+					// Genotypes are indexed in base 3, with gene 0 as the
+					// most significant digit.
+					idx := idx + g3
+					wt := wt * w3
+					dist[idx] += wt
+					touched[idx/64] |= 1 << (idx % 64)
 				}
 			}
 		}
 	}
+}
+
+// Marginal returns the marginal distribution of the given genes (numbered from
+// 0, in the order they are written), with every other gene replaced by its
+// recessive form. Since genes are inherited independently, breeding commutes
+// with taking marginals.
+func (gd GeneticDistribution) Marginal(genes ...int) GeneticDistribution {
+	var mask Genotype
+	for _, i := range genes {
+		mask |= 0b11 << (2 * i)
+	}
+	var rslt GeneticDistribution
+	for g, p := range gd.dist {
+		if p == 0 {
+			continue
+		}
+		rslt.dist[genotypeToIdx[Genotype(idxToGenotype[g])&mask]] += p
+	}
 	reduce(&rslt.dist)
 	return rslt
 }
@@ -483,7 +526,9 @@ func reduce(dist *[81]uint64) {
 		return
 	}
 	for i := range dist {
-		dist[i] /= g
+		if dist[i] != 0 {
+			dist[i] /= g
+		}
 	}
 }
 
@@ -498,23 +543,14 @@ func gcd(u, v uint64) uint64 {
 	}
 
 	// Remove largest factor of 2.
-	shift := 0
-	for (u|v)&1 == 0 {
-		shift++
-		u >>= 1
-		v >>= 1
-	}
+	shift := bits.TrailingZeros64(u | v)
 
 	// Remove additional, non-common factors of 2 from u.
-	for u&1 == 0 {
-		u >>= 1
-	}
+	u >>= bits.TrailingZeros64(u)
 
 	// Loop invariant: u is odd.
 	for v != 0 {
-		for v&1 == 0 {
-			v >>= 1
-		}
+		v >>= bits.TrailingZeros64(v)
 		if u > v {
 			u, v = v, u
 		}
@@ -543,7 +579,7 @@ func init() {
 		}
 	}
 
-	cosmos = mustSpecies("Cosmos", map[string]string{
+	cosmos = mustSpecies("Cosmos", []string{"rryySs", "rrYYSs", "RRyyss"}, map[string]string{
 		"rryyss": "White",
 		"rryySs": "White",
 		"rryySS": "White",
@@ -571,9 +607,11 @@ func init() {
 		"RRYYss": "Black",
 		"RRYYSs": "Black",
 		"RRYYSS": "Red",
-	})
+	},
+		// Black cosmos are known as chocolate cosmos, & are a deep red-brown.
+		PhenotypeSpec{"Black", PhenotypeInfo{Aliases: []string{"Chocolate"}, Color: "#4a1c1c"}})
 
-	hyacinths = mustSpecies("Hyacinths", map[string]string{
+	hyacinths = mustSpecies("Hyacinths", []string{"rryyWw", "rrYYWW", "RRyyWw"}, map[string]string{
 		"rryyWW": "White",
 		"rryyWw": "White",
 		"rryyww": "Blue",
@@ -603,7 +641,7 @@ func init() {
 		"RRYYww": "Purple",
 	})
 
-	lilies = mustSpecies("Lilies", map[string]string{
+	lilies = mustSpecies("Lilies", []string{"rryySS", "rrYYss", "RRyySs"}, map[string]string{
 		"rryyss": "White",
 		"rryySs": "White",
 		"rryySS": "White",
@@ -633,7 +671,7 @@ func init() {
 		"RRYYSS": "White",
 	})
 
-	mums = mustSpecies("Mums", map[string]string{
+	mums = mustSpecies("Mums", []string{"rryyWw", "rrYYWW", "RRyyWW"}, map[string]string{
 		"rryyWW": "White",
 		"rryyWw": "White",
 		"rryyww": "Purple",
@@ -663,7 +701,7 @@ func init() {
 		"RRYYww": "Red",
 	})
 
-	pansies = mustSpecies("Pansies", map[string]string{
+	pansies = mustSpecies("Pansies", []string{"rryyWw", "rrYYWW", "RRyyWW"}, map[string]string{
 		"rryyWW": "White",
 		"rryyWw": "White",
 		"rryyww": "Blue",
@@ -693,7 +731,7 @@ func init() {
 		"RRYYww": "Purple",
 	})
 
-	roses = mustSpecies("Roses", map[string]string{
+	roses = mustSpecies("Roses", []string{"rryyWwss", "rrYYWWss", "RRyyWWSs"}, map[string]string{
 		"rryyWWss": "White",
 		"rryyWWSs": "White",
 		"rryyWWSS": "White",
@@ -777,7 +815,7 @@ func init() {
 		"RRYYwwSS": "White",
 	})
 
-	tulips = mustSpecies("Tulips", map[string]string{
+	tulips = mustSpecies("Tulips", []string{"rryySs", "rrYYss", "RRyySs"}, map[string]string{
 		"rryyss": "White",
 		"rryySs": "White",
 		"rryySS": "White",
@@ -807,7 +845,7 @@ func init() {
 		"RRYYSS": "Purple",
 	})
 
-	windflowers = mustSpecies("Windflowers", map[string]string{
+	windflowers = mustSpecies("Windflowers", []string{"rrooWw", "rrOOWW", "RRooWW"}, map[string]string{
 		"rrooWW": "White",
 		"rrooWw": "White",
 		"rrooww": "Blue",
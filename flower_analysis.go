@@ -0,0 +1,187 @@
+package flower
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// PhenotypeGroup is the set of genotypes of a species sharing a phenotype.
+type PhenotypeGroup struct {
+	Phenotype Phenotype
+	Genotypes []Genotype // in canonical order
+}
+
+// Unique returns true if the genotype in this group can be identified by its
+// phenotype alone.
+func (pg PhenotypeGroup) Unique() bool { return len(pg.Genotypes) == 1 }
+
+// PhenotypeGroups groups all genotypes of this species by phenotype. Groups are
+// returned in phenotype order.
+func (s Species) PhenotypeGroups() []PhenotypeGroup {
+	groups := map[Phenotype][]Genotype{}
+	for _, g := range s.Genotypes() {
+		p := s.Phenotype(g)
+		groups[p] = append(groups[p], g)
+	}
+	rslt := make([]PhenotypeGroup, 0, len(groups))
+	for p, gs := range groups {
+		rslt = append(rslt, PhenotypeGroup{p, gs})
+	}
+	sort.Slice(rslt, func(i, j int) bool { return rslt[i].Phenotype < rslt[j].Phenotype })
+	return rslt
+}
+
+// PhenotypeDistribution returns the odds of each phenotype appearing in the
+// given genetic distribution. Phenotypes which cannot appear are omitted.
+func (s Species) PhenotypeDistribution(gd GeneticDistribution) map[Phenotype]uint64 {
+	rslt := map[Phenotype]uint64{}
+	gd.Visit(func(g Genotype, odds uint64) bool {
+		rslt[s.Phenotype(g)] += odds
+		return true
+	})
+	return rslt
+}
+
+// maxExhaustiveTesters is the largest number of testers among which
+// DistinguishingTestCrosses searches exhaustively.
+const maxExhaustiveTesters = 16
+
+// DistinguishingTestCrosses determines a small set of testers such that each
+// pair of the given genotypes is distinguished by crossing with at least one of
+// the testers, i.e. the two genotypes produce offspring with different
+// phenotype distributions when bred with that tester. Pairs of genotypes that
+// no tester can distinguish are returned as indistinguishable; the returned
+// testers distinguish all other pairs.
+//
+// For small tester sets, such as a species' seeds, the search is exhaustive &
+// finds a smallest set of testers. Larger tester sets are searched greedily,
+// repeatedly choosing the tester distinguishing the most remaining pairs, which
+// may choose more testers than necessary.
+func (s Species) DistinguishingTestCrosses(genotypes, testers []Genotype) (crosses []Genotype, indistinguishable [][2]Genotype) {
+	// Determine which testers distinguish each pair of genotypes.
+	offspring := make([][]map[Phenotype]uint64, len(genotypes))
+	for i, g := range genotypes {
+		offspring[i] = make([]map[Phenotype]uint64, len(testers))
+		for j, t := range testers {
+			offspring[i][j] = s.PhenotypeDistribution(g.ToGeneticDistribution().Breed(t.ToGeneticDistribution()))
+		}
+	}
+	var pairTesters [][]bool // for each distinguishable pair, whether each tester distinguishes it
+	for i := range genotypes {
+		for j := i + 1; j < len(genotypes); j++ {
+			distinguished := make([]bool, len(testers))
+			ok := false
+			for t := range testers {
+				if !samePhenotypeDistribution(offspring[i][t], offspring[j][t]) {
+					distinguished[t], ok = true, true
+				}
+			}
+			if !ok {
+				indistinguishable = append(indistinguishable, [2]Genotype{genotypes[i], genotypes[j]})
+				continue
+			}
+			pairTesters = append(pairTesters, distinguished)
+		}
+	}
+
+	var chosen []bool
+	if len(testers) <= maxExhaustiveTesters {
+		chosen = smallestCover(pairTesters, len(testers))
+	} else {
+		chosen = greedyCover(pairTesters, len(testers))
+	}
+	for t := range testers {
+		if chosen[t] {
+			crosses = append(crosses, testers[t])
+		}
+	}
+	return crosses, indistinguishable
+}
+
+// smallestCover finds the smallest set of testers, among testerCnt testers,
+// covering every pair, by trying every set. Ties are broken in favor of earlier
+// testers. testerCnt must be at most 32.
+func smallestCover(pairTesters [][]bool, testerCnt int) []bool {
+	pairMasks := make([]uint32, len(pairTesters))
+	for i, distinguished := range pairTesters {
+		for t, d := range distinguished {
+			if d {
+				pairMasks[i] |= 1 << t
+			}
+		}
+	}
+	best := uint32(1)<<testerCnt - 1
+	for set := uint32(0); set < uint32(1)<<testerCnt; set++ {
+		if bits.OnesCount32(set) >= bits.OnesCount32(best) {
+			continue
+		}
+		covers := true
+		for _, m := range pairMasks {
+			if m&set == 0 {
+				covers = false
+				break
+			}
+		}
+		if covers {
+			best = set
+		}
+	}
+	rslt := make([]bool, testerCnt)
+	for t := range rslt {
+		rslt[t] = best&(1<<t) != 0
+	}
+	return rslt
+}
+
+// greedyCover finds a set of testers, among testerCnt testers, covering every
+// pair, by repeatedly choosing the tester covering the most uncovered pairs.
+// Ties are broken in favor of earlier testers.
+func greedyCover(pairTesters [][]bool, testerCnt int) []bool {
+	rslt := make([]bool, testerCnt)
+	for len(pairTesters) > 0 {
+		best, bestCnt := 0, 0
+		for t := 0; t < testerCnt; t++ {
+			cnt := 0
+			for _, distinguished := range pairTesters {
+				if distinguished[t] {
+					cnt++
+				}
+			}
+			if cnt > bestCnt {
+				best, bestCnt = t, cnt
+			}
+		}
+		rslt[best] = true
+
+		// Keep only the pairs still uncovered.
+		uncovered := pairTesters[:0]
+		for _, distinguished := range pairTesters {
+			if !distinguished[best] {
+				uncovered = append(uncovered, distinguished)
+			}
+		}
+		pairTesters = uncovered
+	}
+	return rslt
+}
+
+// samePhenotypeDistribution determines if two phenotype distributions, given as
+// odds, describe the same probabilities.
+func samePhenotypeDistribution(a, b map[Phenotype]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var totalA, totalB uint64
+	for _, o := range a {
+		totalA += o
+	}
+	for _, o := range b {
+		totalB += o
+	}
+	for p, oa := range a {
+		if oa*totalB != b[p]*totalA {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+//go:build go1.18
+// +build go1.18
+
+package flower
+
+import (
+	"testing"
+)
+
+var fuzzGenotypes = []string{
+	"RrYyWw", "rryyww", "RRYYWWSS", "RrYywwSs", "rRyYwW", "RrRrWw", "112233", "__yyww",
+	"Rr Yy", " RrYyWw", "RrYyWw ", "RrYyWwS", "ÉéYyWw", "RrYyWwé", "", "Rr",
+}
+
+var fuzzGeneticDistributions = []string{
+	"RrYyWw", "{1:RrYyWw}", "{1:RrYyWw, 2:rryyww}", "{ 1 : RrYyWw , 3 : RRYYWW }",
+	"{1:RrYyWwSs, 1:rryywwss}", "{}", "{", "}", "{,}", "{1:}", "{:RrYyWw}", "{0:RrYyWw}",
+	"{1:RrYyWw, 1:RrYyWw}", "{1:RrYyWw, 1:RrYyWwSs}", "{18446744073709551616:RrYyWw}",
+	"{-1:RrYyWw}", "{1:RrYyWw:2}", "{1:ÉéYyWw}", "{1: RrYyWw}", " {1:RrYyWw} ",
+	"RrYy?? 50%", "rryyW_ss", "{RrYyWw 50%, RRYYWW 25%}", "{1/4:rryyww, 3/4:RRyyww}", "{1.5:RrYyWw}",
+	"(Rr|RR)yyWW", "(1:Rr|3:RR)yyWW", "(Rr 25%|RR 75%)yyWW", "(Rr|RR", "??????", "Roses:Orange from seeds",
+	"Roses:{Pink from White x Red seeds, 1:RRYYWWSS}", "Tulips:Purple from seeds", "Orange from seeds",
+}
+
+func FuzzNewGenotypeSerdeFromExample(f *testing.F) {
+	for _, s := range fuzzGenotypes {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, genotype string) {
+		gs, err := NewGenotypeSerdeFromExample(genotype)
+		if err != nil {
+			return
+		}
+		g, err := gs.ParseGenotype(genotype)
+		if err != nil {
+			t.Fatalf("ParseGenotype(%q) with serde created from it failed: %v", genotype, err)
+		}
+		if got := gs.RenderGenotype(g); got != genotype {
+			t.Errorf("RenderGenotype(ParseGenotype(%q)) = %q", genotype, got)
+		}
+		checkSerdeRoundTrip(t, gs)
+	})
+}
+
+func FuzzParseGenotype(f *testing.F) {
+	for _, s := range fuzzGenotypes {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, genotype string) {
+		for _, s := range []Species{Roses(), Tulips()} {
+			g, err := s.ParseGenotype(genotype)
+			if err != nil {
+				continue
+			}
+			if got := s.RenderGenotype(g); got != genotype {
+				t.Errorf("%s.RenderGenotype(ParseGenotype(%q)) = %q", s.Name(), genotype, got)
+			}
+		}
+	})
+}
+
+func FuzzParseGeneticDistribution(f *testing.F) {
+	for _, s := range fuzzGeneticDistributions {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, geneticDistribution string) {
+		gd, gs, err := parseGeneticDistribution(nil, GenotypeSerde{}, geneticDistribution)
+		if err != nil {
+			return
+		}
+		if gd.IsZero() {
+			t.Fatalf("parseGeneticDistribution(%q) returned zero distribution", geneticDistribution)
+		}
+		checkSerdeRoundTrip(t, gs)
+
+		str := gs.RenderGeneticDistribution(gd)
+		got, err := gs.ParseGeneticDistribution(str)
+		if err != nil {
+			t.Fatalf("ParseGeneticDistribution(%q) (rendered from %q) failed: %v", str, geneticDistribution, err)
+		}
+		if got != gd {
+			t.Errorf("ParseGeneticDistribution(%q) = %q, want %q", str, gs.RenderGeneticDistribution(got), str)
+		}
+	})
+}
+
+// checkSerdeRoundTrip checks that gs can parse each genotype it renders.
+func checkSerdeRoundTrip(t *testing.T, gs GenotypeSerde) {
+	t.Helper()
+	for _, g := range idxToGenotype {
+		if gs.GeneCount() == 3 && g.gene3() != 0 {
+			continue
+		}
+		str := gs.RenderGenotype(g)
+		if got, err := gs.ParseGenotype(str); err != nil || got != g {
+			t.Errorf("ParseGenotype(%q) = (%v, %v), want %v", str, got, err, g)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package flower
+
+import "sort"
+
+// ParentPair is a pair of parent genotypes, along with the probability that a
+// child bred from them has some target genotype.
+type ParentPair struct {
+	Parents     [2]Genotype
+	Probability float64
+}
+
+// ParentsOf determines all pairs of parents, drawn from the given candidate
+// genotypes, that can produce a child of the target genotype. To consider every
+// genotype, pass s.Genotypes(). Each unordered pair
+// (including a genotype paired with itself) is returned once, ordered by
+// decreasing probability of producing the target; ties are broken by the order
+// of the candidates.
+func (s Species) ParentsOf(target Genotype, candidates []Genotype) []ParentPair {
+	targetIdx := genotypeToIdx[target]
+
+	var rslt []ParentPair
+	for i, ga := range candidates {
+		gda := ga.ToGeneticDistribution()
+		for _, gb := range candidates[i:] {
+			child := gda.Breed(gb.ToGeneticDistribution())
+			odds := child.dist[targetIdx]
+			if odds == 0 {
+				continue
+			}
+			var total uint64
+			for _, o := range child.dist {
+				total += o
+			}
+			rslt = append(rslt, ParentPair{[2]Genotype{ga, gb}, float64(odds) / float64(total)})
+		}
+	}
+	sort.SliceStable(rslt, func(i, j int) bool { return rslt[i].Probability > rslt[j].Probability })
+	return rslt
+}
+
+// UniqueGenotypes returns the genotypes of this species that can be identified
+// by phenotype alone, in canonical order.
+func (s Species) UniqueGenotypes() []Genotype {
+	var rslt []Genotype
+	for _, pg := range s.PhenotypeGroups() {
+		if pg.Unique() {
+			rslt = append(rslt, pg.Genotypes[0])
+		}
+	}
+	sort.Slice(rslt, func(i, j int) bool { return genotypeToIdx[rslt[i]] < genotypeToIdx[rslt[j]] })
+	return rslt
+}
+
+// FilterUnique returns those of the given genotypes that can be identified by
+// phenotype alone, in their original order.
+func (s Species) FilterUnique(gs []Genotype) []Genotype {
+	isUnique := map[Genotype]bool{}
+	for _, g := range s.UniqueGenotypes() {
+		isUnique[g] = true
+	}
+	var rslt []Genotype
+	for _, g := range gs {
+		if isUnique[g] {
+			rslt = append(rslt, g)
+		}
+	}
+	return rslt
+}
@@ -0,0 +1,434 @@
+package flower
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Genetic distributions are written as a list of terms, optionally wrapped in
+// curly braces & prefixed by the name of a species:
+//
+//   distribution := [species ":"] ("{" terms "}" | terms)
+//   terms        := term ("," term)*
+//   term         := [odds ":"] body | body odds
+//   body         := pattern | phenotype "from" [phenotype "x" phenotype] "seeds"
+//   pattern      := gene gene gene [gene]
+//   gene         := pair | "(" alternative ("|" alternative)* ")"
+//   alternative  := [odds ":"] pair | pair odds
+//   pair         := two letters or wildcards ("?" or "_"), e.g. "Rr", "R?" or "??"
+//   odds         := an integer, decimal, percentage or fraction, e.g. "3", "0.5", "12.5%" or "1/4"
+//
+// Odds are relative, default to 1, & need not sum to 100%; where terms overlap,
+// their odds add. A pair matches each of the gene's three genotypes (e.g. "rr",
+// "Rr" & "RR") which agrees with it in its non-wildcard positions, & its odds
+// are split evenly between them; so "R?" is Rr or RR with equal odds, & "??" is
+// any of the three.
+//
+// "P from seeds" is a flower of phenotype P obtained from seed flowers: either a
+// seed flower itself, if P is the phenotype of some seed, or a flower of
+// phenotype P bred from two seed flowers of other phenotypes, if there's only one
+// such pair. "P from A x B seeds" is a flower of phenotype P bred from seed
+// flowers of phenotypes A & B.
+
+var (
+	oddsRe      = regexp.MustCompile(`^(?:\d+(?:\.\d+)?%?|\d+/\d+)$`)
+	fromSeedsRe = regexp.MustCompile(`(?i)^(\w+)\s+from\s+(?:(\w+)\s*(?:x|×)\s*(\w+)\s+)?seeds$`)
+)
+
+func parseGeneticDistribution(s *Species, gs GenotypeSerde, geneticDistribution string) (GeneticDistribution, GenotypeSerde, error) {
+	gd, gs, err := parseTerms(s, gs, geneticDistribution)
+	if err != nil {
+		return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("couldn't parse genetic distribution: %v", err)
+	}
+	return gd, gs, nil
+}
+
+func parseTerms(s *Species, gs GenotypeSerde, geneticDistribution string) (GeneticDistribution, GenotypeSerde, error) {
+	str := strings.TrimSpace(geneticDistribution)
+	if i := strings.IndexByte(str, ':'); i >= 0 {
+		if sp, ok := SpeciesByName(strings.TrimSpace(str[:i])); ok {
+			if s != nil && s.name != sp.name {
+				return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("distribution is of %s, not %s", sp.name, s.name)
+			}
+			if gs.IsZero() {
+				gs = sp.serde
+			} else if gs.GeneCount() != sp.GeneCount() {
+				return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("%s have %d genes, not %d", sp.name, sp.GeneCount(), gs.GeneCount())
+			}
+			s, str = &sp, strings.TrimSpace(str[i+1:])
+		}
+	}
+	if strings.HasPrefix(str, "{") != strings.HasSuffix(str, "}") {
+		return GeneticDistribution{}, GenotypeSerde{}, errors.New("unbalanced curly braces")
+	}
+	if strings.HasPrefix(str, "{") {
+		str = str[1 : len(str)-1]
+	}
+
+	rslt := &ratDistribution{}
+	plain := map[string]bool{} // the plain genotypes given as terms so far
+	for _, term := range strings.Split(str, ",") {
+		term = strings.TrimSpace(term)
+		odds, body, err := splitOdds(term)
+		if err != nil {
+			return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("term %q: %v", term, err)
+		}
+
+		var termDist *ratDistribution
+		if m := fromSeedsRe.FindStringSubmatch(body); m != nil {
+			if s == nil {
+				return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("term %q: species required, e.g. \"Roses:%s\"", term, body)
+			}
+			termDist, err = fromSeeds(*s, m[1], m[2:])
+		} else {
+			var genes [][]geneAlt
+			if genes, err = parsePattern(body); err == nil && gs.IsZero() {
+				gs, err = inferSerde(genes)
+			}
+			if err == nil && isPlain(genes) {
+				// Terms may overlap only through wildcards or
+				// alternatives; a repeated genotype is likely a typo.
+				if plain[body] {
+					err = errors.New("duplicate genotype")
+				}
+				plain[body] = true
+			}
+			if err == nil {
+				termDist, err = expandPattern(gs, genes)
+			}
+		}
+		if err != nil {
+			return GeneticDistribution{}, GenotypeSerde{}, fmt.Errorf("term %q: %v", term, err)
+		}
+		rslt.addScaled(termDist, odds)
+	}
+
+	gd, err := rslt.toGeneticDistribution()
+	if err != nil {
+		return GeneticDistribution{}, GenotypeSerde{}, err
+	}
+	return gd, gs, nil
+}
+
+// splitOdds splits a term (or alternative) into its odds & body. Terms without
+// explicit odds have odds 1.
+func splitOdds(term string) (*big.Rat, string, error) {
+	body := term
+	var prefix, suffix string
+	if i := strings.IndexByte(body, ':'); i >= 0 && !strings.ContainsAny(body[:i], "(|") {
+		prefix, body = strings.TrimSpace(body[:i]), strings.TrimSpace(body[i+1:])
+		if prefix == "" {
+			return nil, "", errors.New("missing odds")
+		}
+	}
+	if i := strings.LastIndexAny(body, " \t"); i >= 0 && oddsRe.MatchString(body[i+1:]) {
+		suffix, body = body[i+1:], strings.TrimSpace(body[:i])
+	}
+	if body == "" {
+		return nil, "", errors.New("empty term")
+	}
+
+	switch {
+	case prefix != "" && suffix != "":
+		return nil, "", errors.New("odds given twice")
+	case prefix != "":
+		odds, err := parseOdds(prefix)
+		return odds, body, err
+	case suffix != "":
+		odds, err := parseOdds(suffix)
+		return odds, body, err
+	default:
+		return big.NewRat(1, 1), body, nil
+	}
+}
+
+func parseOdds(odds string) (*big.Rat, error) {
+	if !oddsRe.MatchString(odds) {
+		return nil, fmt.Errorf("couldn't parse odds %q", odds)
+	}
+	rslt, ok := new(big.Rat).SetString(strings.TrimSuffix(odds, "%"))
+	if !ok {
+		return nil, fmt.Errorf("couldn't parse odds %q", odds)
+	}
+	if strings.HasSuffix(odds, "%") {
+		rslt.Quo(rslt, big.NewRat(100, 1))
+	}
+	if rslt.Sign() == 0 {
+		return nil, fmt.Errorf("odds %q are zero", odds)
+	}
+	return rslt, nil
+}
+
+// geneAlt is one alternative for a gene in a genotype pattern.
+type geneAlt struct {
+	odds *big.Rat
+	pair string
+}
+
+// parsePattern parses a genotype pattern into the alternatives for each gene.
+func parsePattern(pattern string) ([][]geneAlt, error) {
+	var rslt [][]geneAlt
+	for rest := pattern; len(rest) > 0; {
+		if rest[0] != '(' {
+			if len(rest) < 2 || !isPair(rest[:2]) {
+				return nil, fmt.Errorf("couldn't parse gene %q", firstGene(rest))
+			}
+			rslt = append(rslt, []geneAlt{{big.NewRat(1, 1), rest[:2]}})
+			rest = rest[2:]
+			continue
+		}
+
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed parenthesis in %q", pattern)
+		}
+		var alts []geneAlt
+		for _, alt := range strings.Split(rest[1:end], "|") {
+			odds, pair, err := splitOdds(strings.TrimSpace(alt))
+			if err != nil {
+				return nil, fmt.Errorf("alternative %q: %v", alt, err)
+			}
+			if !isPair(pair) {
+				return nil, fmt.Errorf("couldn't parse gene %q", pair)
+			}
+			alts = append(alts, geneAlt{odds, pair})
+		}
+		rslt = append(rslt, alts)
+		rest = rest[end+1:]
+	}
+	if len(rslt) != 3 && len(rslt) != 4 {
+		return nil, fmt.Errorf("genotype %q has %d genes (expected 3 or 4)", pattern, len(rslt))
+	}
+	return rslt, nil
+}
+
+// firstGene returns a prefix of s for use in error messages about the gene at
+// the start of s.
+func firstGene(s string) string {
+	for i := range s {
+		if i >= 2 {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// isPlain determines if a genotype pattern names a single genotype, without
+// wildcards or alternatives.
+func isPlain(genes [][]geneAlt) bool {
+	for _, alts := range genes {
+		if len(alts) != 1 || !isLetter(alts[0].pair[0]) || !isLetter(alts[0].pair[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPair(pair string) bool {
+	return len(pair) == 2 && isPairChar(pair[0]) && isPairChar(pair[1])
+}
+
+func isPairChar(c byte) bool { return isLetter(c) || c == '?' || c == '_' }
+
+func isLetter(c byte) bool { return ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') }
+
+// inferSerde infers a serde from the letters used in a genotype pattern.
+func inferSerde(genes [][]geneAlt) (GenotypeSerde, error) {
+	var example strings.Builder
+	for i, alts := range genes {
+		var letter string
+	alts:
+		for _, alt := range alts {
+			for j := 0; j < len(alt.pair); j++ {
+				if isLetter(alt.pair[j]) {
+					letter = alt.pair[j : j+1]
+					break alts
+				}
+			}
+		}
+		if letter == "" {
+			return GenotypeSerde{}, fmt.Errorf("couldn't determine letter of gene %d", i+1)
+		}
+		example.WriteString(strings.ToUpper(letter) + strings.ToLower(letter))
+	}
+	return NewGenotypeSerdeFromExample(example.String())
+}
+
+// expandPattern returns the distribution described by a genotype pattern,
+// normalized so that the odds sum to 1.
+func expandPattern(gs GenotypeSerde, genes [][]geneAlt) (*ratDistribution, error) {
+	if len(genes) != gs.GeneCount() {
+		return nil, fmt.Errorf("genotype has %d genes (expected %d)", len(genes), gs.GeneCount())
+	}
+
+	// Determine the odds of each of each gene's genotypes.
+	serdeGenes := [4][3]string{gs.gene0, gs.gene1, gs.gene2, gs.gene3}
+	var geneOdds [4][3]big.Rat
+	for i := range geneOdds {
+		if i >= len(genes) {
+			geneOdds[i][0].SetInt64(1)
+			continue
+		}
+		total := new(big.Rat)
+		for _, alt := range genes[i] {
+			var matches []int
+			for j, str := range serdeGenes[i] {
+				if (alt.pair[0] == str[0] || !isLetter(alt.pair[0])) && (alt.pair[1] == str[1] || !isLetter(alt.pair[1])) {
+					matches = append(matches, j)
+				}
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("unparsable gene %q", alt.pair)
+			}
+			odds := new(big.Rat).Quo(alt.odds, big.NewRat(int64(len(matches)), 1))
+			for _, j := range matches {
+				geneOdds[i][j].Add(&geneOdds[i][j], odds)
+			}
+			total.Add(total, alt.odds)
+		}
+		for j := range geneOdds[i] {
+			geneOdds[i][j].Quo(&geneOdds[i][j], total)
+		}
+	}
+
+	rslt := &ratDistribution{}
+	for i, g := range idxToGenotype {
+		odds := new(big.Rat).Mul(&geneOdds[0][g.gene0()], &geneOdds[1][g.gene1()])
+		odds.Mul(odds, &geneOdds[2][g.gene2()])
+		odds.Mul(odds, &geneOdds[3][g.gene3()])
+		rslt[i].Set(odds)
+	}
+	return rslt, nil
+}
+
+// fromSeeds returns the distribution described by a "from seeds" shorthand,
+// normalized so that the odds sum to 1. parents holds the names of the parents'
+// phenotypes, or empty strings if they weren't given.
+func fromSeeds(s Species, phenotype string, parents []string) (*ratDistribution, error) {
+	p, err := s.ParsePhenotype(phenotype)
+	if err != nil {
+		return nil, err
+	}
+	seeds := s.Seeds()
+	var gds []GeneticDistribution
+	if parents[0] == "" {
+		for _, g := range seeds {
+			if s.Phenotype(g) == p {
+				gds = append(gds, g.ToGeneticDistribution())
+			}
+		}
+	}
+
+	if len(gds) == 0 {
+		var pa, pb Phenotype
+		if parents[0] != "" {
+			if pa, err = s.ParsePhenotype(parents[0]); err != nil {
+				return nil, err
+			}
+			if pb, err = s.ParsePhenotype(parents[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		var crosses []string
+		for i, ga := range seeds {
+			for _, gb := range seeds[i:] {
+				ppa, ppb := s.Phenotype(ga), s.Phenotype(gb)
+				if parents[0] == "" && (ppa == p || ppb == p) {
+					continue
+				}
+				if parents[0] != "" && !(ppa == pa && ppb == pb) && !(ppa == pb && ppb == pa) {
+					continue
+				}
+				child := ga.ToGeneticDistribution().Breed(gb.ToGeneticDistribution())
+				child = child.Update(func(mgd *MutableGeneticDistribution) {
+					child.Visit(func(g Genotype, _ uint64) bool {
+						if s.Phenotype(g) != p {
+							mgd.SetOdds(g, 0)
+						}
+						return true
+					})
+				})
+				if !child.IsZero() {
+					gds = append(gds, child)
+					crosses = append(crosses, fmt.Sprintf("%s x %s", ppa, ppb))
+				}
+			}
+		}
+
+		switch {
+		case len(gds) == 0 && parents[0] == "":
+			return nil, fmt.Errorf("%s %s can't be bred from seeds", p, s.name)
+		case len(gds) == 0:
+			return nil, fmt.Errorf("%s %s can't be bred from %s x %s seeds", p, s.name, pa, pb)
+		case len(gds) > 1 && parents[0] == "":
+			return nil, fmt.Errorf("%s %s can be bred from several pairs of seeds (%s); specify one, e.g. %q", p, s.name, strings.Join(crosses, ", "), fmt.Sprintf("%s from %s seeds", p, crosses[0]))
+		}
+	}
+
+	rslt := &ratDistribution{}
+	for _, gd := range gds {
+		rslt.addScaled(normalize(gd), big.NewRat(1, int64(len(gds))))
+	}
+	return rslt, nil
+}
+
+// ratDistribution is a distribution whose odds are arbitrary non-negative
+// rationals, indexed in canonical order.
+type ratDistribution [81]big.Rat
+
+func normalize(gd GeneticDistribution) *ratDistribution {
+	var total uint64
+	gd.Visit(func(_ Genotype, odds uint64) bool {
+		total += odds
+		return true
+	})
+	rslt := &ratDistribution{}
+	for i, odds := range gd.dist {
+		rslt[i].SetFrac(new(big.Int).SetUint64(odds), new(big.Int).SetUint64(total))
+	}
+	return rslt
+}
+
+// addScaled adds each of o's odds, multiplied by scale, to rd.
+func (rd *ratDistribution) addScaled(o *ratDistribution, scale *big.Rat) {
+	var odds big.Rat
+	for i := range rd {
+		rd[i].Add(&rd[i], odds.Mul(&o[i], scale))
+	}
+}
+
+// toGeneticDistribution returns the GeneticDistribution with the same
+// proportions as rd.
+func (rd *ratDistribution) toGeneticDistribution() (GeneticDistribution, error) {
+	// Scale the odds to integers, then reduce them.
+	lcm := big.NewInt(1)
+	var g big.Int
+	for i := range rd {
+		denom := rd[i].Denom()
+		g.GCD(nil, nil, lcm, denom)
+		lcm.Mul(lcm, denom).Quo(lcm, &g)
+	}
+	var nums [81]big.Int
+	var numGCD big.Int
+	for i := range rd {
+		nums[i].Mul(rd[i].Num(), lcm).Quo(&nums[i], rd[i].Denom())
+		numGCD.GCD(nil, nil, &numGCD, &nums[i])
+	}
+	if numGCD.Sign() == 0 {
+		return GeneticDistribution{}, errors.New("distribution is empty")
+	}
+
+	var rslt GeneticDistribution
+	for i := range nums {
+		nums[i].Quo(&nums[i], &numGCD)
+		if !nums[i].IsUint64() {
+			return GeneticDistribution{}, errors.New("odds too large")
+		}
+		rslt.dist[i] = nums[i].Uint64()
+	}
+	return rslt, nil
+}
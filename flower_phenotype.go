@@ -0,0 +1,261 @@
+package flower
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Phenotype represents a specific outward appearance for a species, e.g. Red or Yellow.
+// Beyond the built-in phenotypes, a species may define its own (such as golden
+// flowers, or colors used by other data sets) in its SpeciesSpec. These are
+// numbered after the built-in phenotypes, & have meaning only together with
+// their species; Species.RenderPhenotype & Species.PhenotypeInfo describe them.
+type Phenotype uint8
+
+const (
+	Unknown Phenotype = iota
+	White
+	Pink
+	Red
+	Orange
+	Yellow
+	Green
+	Blue
+	Purple
+	Black
+
+	firstSpeciesPhenotype // the first phenotype specific to a species
+)
+
+// PhenotypeInfo describes a phenotype.
+type PhenotypeInfo struct {
+	Name    string   // a human-readable name, e.g. "Red"; also used when marshaling
+	Aliases []string // other names accepted when parsing
+	Color   string   // an approximation of the color of flowers with this phenotype, as a hex RGB triple such as "#f28c28"
+}
+
+var (
+	phenotypeInfos = [firstSpeciesPhenotype]PhenotypeInfo{
+		Unknown: {Color: "#cccccc"},
+		White:   {Name: "White", Color: "#f5f5f0"},
+		Pink:    {Name: "Pink", Color: "#f7a8c8"},
+		Red:     {Name: "Red", Color: "#d7263d"},
+		Orange:  {Name: "Orange", Color: "#f28c28"},
+		Yellow:  {Name: "Yellow", Color: "#f6d743"},
+		Green:   {Name: "Green", Color: "#6abf4b"},
+		Blue:    {Name: "Blue", Color: "#3a6ee8"},
+		Purple:  {Name: "Purple", Color: "#8e44ad"},
+		Black:   {Name: "Black", Color: "#2b2b2b"},
+	}
+
+	colorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// ParsePhenotype parses a built-in phenotype from its name, ignoring case.
+// Species.ParsePhenotype also parses a species' own phenotypes & aliases.
+func ParsePhenotype(phenotype string) (Phenotype, error) {
+	if p, ok := lookupPhenotype(phenotype); ok {
+		return p, nil
+	}
+	return Unknown, fmt.Errorf("unknown phenotype %q", phenotype)
+}
+
+// AllPhenotypes returns all built-in phenotypes other than Unknown, in order.
+func AllPhenotypes() []Phenotype {
+	rslt := make([]Phenotype, 0, len(phenotypeInfos)-1)
+	for p := White; p < firstSpeciesPhenotype; p++ {
+		rslt = append(rslt, p)
+	}
+	return rslt
+}
+
+// lookupPhenotype finds a built-in phenotype by name.
+func lookupPhenotype(name string) (_ Phenotype, ok bool) {
+	for p := White; p < firstSpeciesPhenotype; p++ {
+		if strings.EqualFold(phenotypeInfos[p].Name, name) {
+			return p, true
+		}
+	}
+	return Unknown, false
+}
+
+// Info returns a description of this built-in phenotype. Unknown phenotypes,
+// & those specific to a species, have no name.
+func (p Phenotype) Info() PhenotypeInfo {
+	if p >= firstSpeciesPhenotype {
+		return phenotypeInfos[Unknown]
+	}
+	return phenotypeInfos[p]
+}
+
+func (p Phenotype) String() string {
+	if name := p.Info().Name; name != "" {
+		return name
+	}
+	if p >= firstSpeciesPhenotype {
+		return fmt.Sprintf("[species-specific phenotype ID %d]", p)
+	}
+	return fmt.Sprintf("[unknown phenotype ID %d]", p)
+}
+
+// Color returns an approximation of the color of flowers with this phenotype,
+// as a hex RGB triple such as "#f28c28". Species.PhenotypeInfo gives the
+// color of a phenotype for a specific species.
+func (p Phenotype) Color() string { return p.Info().Color }
+
+// MarshalText encodes the built-in phenotype as its name. Phenotypes specific to
+// a species are encoded by name with Species.RenderPhenotype instead.
+func (p Phenotype) MarshalText() ([]byte, error) {
+	name := p.Info().Name
+	if name == "" {
+		return nil, fmt.Errorf("couldn't marshal phenotype: %s", p)
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a built-in phenotype from its name.
+func (p *Phenotype) UnmarshalText(text []byte) error {
+	rslt, err := ParsePhenotype(string(text))
+	if err != nil {
+		return err
+	}
+	*p = rslt
+	return nil
+}
+
+// PhenotypeInfo returns a description of the given phenotype as it applies to
+// this species, which may give it a different name, additional aliases or a
+// different color.
+func (s Species) PhenotypeInfo(p Phenotype) PhenotypeInfo {
+	info, ok := s.phenotypeInfo[p]
+	if !ok {
+		return p.Info()
+	}
+	info.Aliases = append([]string(nil), info.Aliases...)
+	if p < firstSpeciesPhenotype {
+		rslt := p.Info()
+		if info.Name != "" {
+			rslt.Name = info.Name
+		}
+		rslt.Aliases = info.Aliases
+		if info.Color != "" {
+			rslt.Color = info.Color
+		}
+		return rslt
+	}
+	return info
+}
+
+// RenderPhenotype returns the name of the given phenotype for this species.
+func (s Species) RenderPhenotype(p Phenotype) string {
+	if name := s.PhenotypeInfo(p).Name; name != "" {
+		return name
+	}
+	return p.String()
+}
+
+// ParsePhenotype parses one of this species' phenotypes from its name or one
+// of its aliases (including those specific to this species), ignoring case.
+func (s Species) ParsePhenotype(phenotype string) (Phenotype, error) {
+	p, err := s.lookupPhenotype(phenotype)
+	if err != nil {
+		return Unknown, err
+	}
+	if !s.hasPhenotype(p) {
+		return Unknown, fmt.Errorf("%s have no %s flowers", s.name, s.RenderPhenotype(p))
+	}
+	return p, nil
+}
+
+// lookupPhenotype parses a phenotype from its name or one of its aliases
+// (including those specific to this species), ignoring case. The phenotype
+// need not be one of this species' phenotypes.
+func (s Species) lookupPhenotype(phenotype string) (Phenotype, error) {
+	for p, info := range s.phenotypeInfo {
+		if strings.EqualFold(info.Name, phenotype) {
+			return p, nil
+		}
+		for _, alias := range info.Aliases {
+			if strings.EqualFold(alias, phenotype) {
+				return p, nil
+			}
+		}
+	}
+	return ParsePhenotype(phenotype)
+}
+
+func (s Species) hasPhenotype(p Phenotype) bool {
+	for _, sp := range s.Phenotypes() {
+		if sp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// setPhenotypeInfo sets the species-specific names, aliases & colors of
+// phenotypes, & adds the phenotypes specific to the species. Since
+// species-specific names & aliases are checked before other names, each must
+// not name any other phenotype, ignoring case.
+func (s *Species) setPhenotypeInfo(specs []PhenotypeSpec) error {
+	s.phenotypeInfo = map[Phenotype]PhenotypeInfo{}
+	names := map[string]Phenotype{}
+	next := firstSpeciesPhenotype
+	for _, spec := range specs {
+		info := spec.PhenotypeInfo
+		var p Phenotype
+		if spec.Builtin != "" {
+			var err error
+			if p, err = ParsePhenotype(spec.Builtin); err != nil {
+				return err
+			}
+			if _, ok := s.phenotypeInfo[p]; ok {
+				return fmt.Errorf("got phenotype info for %s twice", p)
+			}
+		} else {
+			if info.Name == "" {
+				return errors.New("got unnamed phenotype")
+			}
+			if info.Color == "" {
+				return fmt.Errorf("got no color for phenotype %q", info.Name)
+			}
+			if next == 0 {
+				return errors.New("too many phenotypes")
+			}
+			p, next = next, next+1
+		}
+		if info.Color != "" && !colorRe.MatchString(info.Color) {
+			return fmt.Errorf("invalid color %q for phenotype %q", info.Color, phenotypeName(p, info))
+		}
+
+		ns := info.Aliases
+		if info.Name != "" {
+			ns = append([]string{info.Name}, ns...)
+		}
+		for _, name := range ns {
+			if name == "" || strings.TrimSpace(name) != name {
+				return fmt.Errorf("invalid name %q for phenotype %q", name, phenotypeName(p, info))
+			}
+			key := strings.ToLower(name)
+			if q, ok := names[key]; ok {
+				return fmt.Errorf("name %q given for both %q & %q", name, phenotypeName(q, s.phenotypeInfo[q]), phenotypeName(p, info))
+			}
+			if q, err := ParsePhenotype(name); err == nil && q != p {
+				return fmt.Errorf("name %q for phenotype %q already names %s", name, phenotypeName(p, info), q)
+			}
+			names[key] = p
+		}
+		s.phenotypeInfo[p] = PhenotypeInfo{info.Name, append([]string(nil), info.Aliases...), info.Color}
+	}
+	return nil
+}
+
+// phenotypeName names phenotype p, given its species-specific description.
+func phenotypeName(p Phenotype, info PhenotypeInfo) string {
+	if info.Name != "" {
+		return info.Name
+	}
+	return p.String()
+}
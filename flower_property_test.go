@@ -0,0 +1,192 @@
+package flower
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// randomDistribution is a random genetic distribution of a random species, for
+// use with testing/quick.
+type randomDistribution struct {
+	s  Species
+	gd GeneticDistribution
+}
+
+func (randomDistribution) Generate(r *rand.Rand, size int) reflect.Value {
+	species := AllSpecies()
+	s := species[r.Intn(len(species))]
+	gs := s.Genotypes()
+	n := 1 + r.Intn(len(gs))
+	if size > 0 && n > size {
+		n = 1 + r.Intn(size)
+	}
+	gd := GeneticDistribution{}.Update(func(mgd *MutableGeneticDistribution) {
+		for _, i := range r.Perm(len(gs))[:n] {
+			mgd.SetOdds(gs[i], 1+uint64(r.Intn(100)))
+		}
+	})
+	return reflect.ValueOf(randomDistribution{s, gd})
+}
+
+func checkProperty(t *testing.T, f interface{}) {
+	t.Helper()
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// isReduced determines if the odds of gd have no common factor.
+func isReduced(gd GeneticDistribution) bool {
+	var g uint64
+	gd.Visit(func(_ Genotype, odds uint64) bool {
+		g = gcd(g, odds)
+		return true
+	})
+	return g == 1
+}
+
+func TestPropertyGetOdds(t *testing.T) {
+	checkProperty(t, func(rd randomDistribution) bool {
+		visited := map[Genotype]uint64{}
+		rd.gd.Visit(func(g Genotype, odds uint64) bool {
+			visited[g] = odds
+			return true
+		})
+		for _, g := range idxToGenotype {
+			if rd.gd.GetOdds(g) != visited[g] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestPropertyParseRenderRoundTrip(t *testing.T) {
+	checkProperty(t, func(rd randomDistribution) bool {
+		str := rd.s.RenderGeneticDistribution(rd.gd)
+		gd, err := rd.s.ParseGeneticDistribution(str)
+		return err == nil && gd == rd.gd && rd.s.RenderGeneticDistribution(gd) == str
+	})
+}
+
+func TestPropertyParseGenotypeRoundTrip(t *testing.T) {
+	for _, s := range AllSpecies() {
+		for _, g := range s.Genotypes() {
+			str := s.RenderGenotype(g)
+			if got, err := s.ParseGenotype(str); err != nil || got != g {
+				t.Errorf("%s.ParseGenotype(%q) = (%v, %v), want %v", s.Name(), str, got, err, g)
+			}
+		}
+	}
+}
+
+func TestPropertyBreedSymmetric(t *testing.T) {
+	checkProperty(t, func(a, b randomDistribution) bool {
+		b.gd = sameSpecies(a.s, b.gd)
+		return a.gd.Breed(b.gd) == b.gd.Breed(a.gd)
+	})
+}
+
+func TestPropertyBreedNormalized(t *testing.T) {
+	checkProperty(t, func(a, b randomDistribution) bool {
+		child := a.gd.Breed(sameSpecies(a.s, b.gd))
+		if child.IsZero() || !isReduced(child) {
+			return false
+		}
+
+		// Children must be of the parents' species.
+		ok := true
+		child.Visit(func(g Genotype, _ uint64) bool {
+			ok = a.s.Phenotype(g) != Unknown
+			return ok
+		})
+		return ok
+	})
+}
+
+func TestPropertyBreedHomozygous(t *testing.T) {
+	// A homozygous genotype always breeds true.
+	for _, s := range AllSpecies() {
+		for _, g := range s.Genotypes() {
+			if g.gene0() == 1 || g.gene1() == 1 || g.gene2() == 1 || g.gene3() == 1 {
+				continue
+			}
+			gd := g.ToGeneticDistribution()
+			if got := gd.Breed(gd); got != gd {
+				t.Errorf("%s self-bred = %s", s.RenderGenotype(g), s.RenderGeneticDistribution(got))
+			}
+		}
+	}
+}
+
+func TestPropertyReduce(t *testing.T) {
+	checkProperty(t, func(rd randomDistribution, k uint8) bool {
+		if !isReduced(rd.gd) {
+			return false
+		}
+
+		// Scaling all odds must not change the reduced distribution.
+		scaled := rd.gd.dist
+		for i := range scaled {
+			scaled[i] *= uint64(k) + 1
+		}
+		reduce(&scaled)
+		if scaled != rd.gd.dist {
+			return false
+		}
+
+		// Reducing is idempotent.
+		reduce(&scaled)
+		return scaled == rd.gd.dist
+	})
+}
+
+func TestPropertyUpdate(t *testing.T) {
+	checkProperty(t, func(rd randomDistribution, gIdx uint8, odds uint8) bool {
+		gs := rd.s.Genotypes()
+		g := gs[int(gIdx)%len(gs)]
+		orig := rd.gd
+
+		// A no-op update leaves the distribution unchanged.
+		if rd.gd.Update(func(*MutableGeneticDistribution) {}) != orig {
+			return false
+		}
+
+		// Updates see the existing odds, & don't modify the original.
+		var seen uint64
+		updated := rd.gd.Update(func(mgd *MutableGeneticDistribution) {
+			seen = mgd.GetOdds(g)
+			mgd.SetOdds(g, uint64(odds))
+			if mgd.GetOdds(g) != uint64(odds) {
+				seen = ^uint64(0)
+			}
+		})
+		if seen != orig.GetOdds(g) || rd.gd != orig {
+			return false
+		}
+
+		// The result is the original with the new odds, reduced.
+		want := orig.dist
+		want[genotypeToIdx[g]] = uint64(odds)
+		reduce(&want)
+		return updated.dist == want && (updated.IsZero() || isReduced(updated))
+	})
+}
+
+// sameSpecies restricts gd to genotypes of species s.
+func sameSpecies(s Species, gd GeneticDistribution) GeneticDistribution {
+	rslt := gd.Update(func(mgd *MutableGeneticDistribution) {
+		gd.Visit(func(g Genotype, _ uint64) bool {
+			if s.Phenotype(g) == Unknown {
+				mgd.SetOdds(g, 0)
+			}
+			return true
+		})
+	})
+	if rslt.IsZero() {
+		return s.Seeds()[0].ToGeneticDistribution()
+	}
+	return rslt
+}
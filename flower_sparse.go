@@ -0,0 +1,237 @@
+package flower
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// SparseGeneticDistribution represents a probability distribution over all
+// possible genotypes by listing only the genotypes with non-zero odds, in
+// canonical order. Most distributions have only a few possible genotypes, so
+// breeding & visiting a SparseGeneticDistribution is usually much faster than
+// doing so with the equivalent GeneticDistribution. The zero value is the zero
+// distribution.
+type SparseGeneticDistribution struct{ entries []sparseEntry }
+
+type sparseEntry struct {
+	g    Genotype
+	odds uint64
+}
+
+func (gd GeneticDistribution) ToSparse() SparseGeneticDistribution { return toSparse(&gd.dist) }
+
+func toSparse(dist *[81]uint64) SparseGeneticDistribution {
+	n := 0
+	for _, p := range dist {
+		if p != 0 {
+			n++
+		}
+	}
+	if n == 0 {
+		return SparseGeneticDistribution{}
+	}
+	entries := make([]sparseEntry, 0, n)
+	for i, p := range dist {
+		if p != 0 {
+			entries = append(entries, sparseEntry{Genotype(idxToGenotype[i]), p})
+		}
+	}
+	return SparseGeneticDistribution{entries}
+}
+
+func (sgd SparseGeneticDistribution) ToGeneticDistribution() GeneticDistribution {
+	var rslt GeneticDistribution
+	for _, e := range sgd.entries {
+		rslt.dist[genotypeToIdx[e.g]] = e.odds
+	}
+	return rslt
+}
+
+func (sgd SparseGeneticDistribution) IsZero() bool { return len(sgd.entries) == 0 }
+
+// Equal determines if two distributions are the same.
+func (sgd SparseGeneticDistribution) Equal(o SparseGeneticDistribution) bool {
+	if len(sgd.entries) != len(o.entries) {
+		return false
+	}
+	for i, e := range sgd.entries {
+		if e != o.entries[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (sgd SparseGeneticDistribution) Update(f func(*MutableGeneticDistribution)) SparseGeneticDistribution {
+	return sgd.ToGeneticDistribution().Update(f).ToSparse()
+}
+
+func (sgd SparseGeneticDistribution) Visit(f func(_ Genotype, odds uint64) bool) {
+	for _, e := range sgd.entries {
+		if !f(e.g, e.odds) {
+			break
+		}
+	}
+}
+
+// Filter returns the distribution restricted to the genotypes for which keep
+// returns true.
+func (sgd SparseGeneticDistribution) Filter(keep func(Genotype) bool) SparseGeneticDistribution {
+	for i, e := range sgd.entries {
+		if keep(e.g) {
+			continue
+		}
+
+		// Some genotype is discarded, so a new distribution is needed.
+		entries := make([]sparseEntry, i, len(sgd.entries)-1)
+		copy(entries, sgd.entries[:i])
+		for _, e := range sgd.entries[i+1:] {
+			if keep(e.g) {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) == 0 {
+			return SparseGeneticDistribution{}
+		}
+		reduceSparse(entries)
+		return SparseGeneticDistribution{entries}
+	}
+	return sgd
+}
+
+func (sgda SparseGeneticDistribution) Breed(sgdb SparseGeneticDistribution) SparseGeneticDistribution {
+	// Odds are accumulated densely, noting which genotypes are touched so that
+	// the result can be collected without scanning every genotype.
+	var dist [81]uint64
+	var touched [2]uint64
+	for _, a := range sgda.entries {
+		for _, b := range sgdb.entries {
+			breedInto(&dist, &touched, a.g, b.g, a.odds*b.odds)
+		}
+	}
+	n := bits.OnesCount64(touched[0]) + bits.OnesCount64(touched[1])
+	if n == 0 {
+		return SparseGeneticDistribution{}
+	}
+	entries := make([]sparseEntry, 0, n)
+	for w, m := range touched {
+		for ; m != 0; m &= m - 1 {
+			idx := 64*w + bits.TrailingZeros64(m)
+			entries = append(entries, sparseEntry{Genotype(idxToGenotype[idx]), dist[idx]})
+		}
+	}
+	reduceSparse(entries)
+	return SparseGeneticDistribution{entries}
+}
+
+func reduceSparse(entries []sparseEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	g := entries[0].odds
+	for _, e := range entries[1:] {
+		if g == 1 {
+			return
+		}
+		g = gcd(g, e.odds)
+	}
+	if g == 1 {
+		return
+	}
+	for i := range entries {
+		entries[i].odds /= g
+	}
+}
+
+// MarshalBinary encodes the distribution as, for each genotype with non-zero
+// odds in order, the genotype followed by its odds as a uvarint.
+func (sgd SparseGeneticDistribution) MarshalBinary() ([]byte, error) {
+	return sgd.AppendBinary(make([]byte, 0, len(sgd.entries)*(1+binary.MaxVarintLen64))), nil
+}
+
+// AppendBinary appends the encoding of the distribution given by MarshalBinary
+// to buf, returning the extended buffer.
+func (sgd SparseGeneticDistribution) AppendBinary(buf []byte) []byte {
+	var oddsBuf [binary.MaxVarintLen64]byte
+	for _, e := range sgd.entries {
+		buf = append(buf, byte(e.g))
+		buf = append(buf, oddsBuf[:binary.PutUvarint(oddsBuf[:], e.odds)]...)
+	}
+	return buf
+}
+
+// UnmarshalBinary decodes a distribution encoded by MarshalBinary.
+func (sgd *SparseGeneticDistribution) UnmarshalBinary(data []byte) error {
+	var entries []sparseEntry
+	for rest, prev := data, -1; len(rest) > 0; {
+		g, odds, n := decodeEntry(rest, prev)
+		if n == 0 {
+			return decodeError(rest, prev)
+		}
+		entries = append(entries, sparseEntry{g, odds})
+		rest, prev = rest[n:], genotypeToIdx[g]
+	}
+	sgd.entries = entries
+	return nil
+}
+
+// MarshalBinary encodes the distribution in the same way as its sparse form.
+func (gd GeneticDistribution) MarshalBinary() ([]byte, error) { return gd.ToSparse().MarshalBinary() }
+
+// UnmarshalBinary decodes a distribution encoded by MarshalBinary.
+func (gd *GeneticDistribution) UnmarshalBinary(data []byte) error {
+	// Decode in place, since distributions are large enough that copying them
+	// dominates decoding.
+	gd.dist = [81]uint64{}
+	for rest, prev := data, -1; len(rest) > 0; {
+		g, odds, n := decodeEntry(rest, prev)
+		if n == 0 {
+			gd.dist = [81]uint64{}
+			return decodeError(rest, prev)
+		}
+		prev = genotypeToIdx[g]
+		gd.dist[prev] = odds
+		rest = rest[n:]
+	}
+	return nil
+}
+
+// decodeEntry decodes the genotype & odds at the start of data, which must
+// follow the genotype with index prev. It returns the number of bytes decoded,
+// or 0 if the entry is invalid.
+func decodeEntry(data []byte, prev int) (_ Genotype, odds uint64, n int) {
+	g := Genotype(data[0])
+	if g&(g>>1)&0b01010101 != 0 || genotypeToIdx[g] <= prev {
+		return 0, 0, 0
+	}
+	if len(data) > 1 && data[1] < 0x80 {
+		// Fast path for small odds, which are encoded as a single byte.
+		odds, n = uint64(data[1]), 1
+	} else if odds, n = binary.Uvarint(data[1:]); n <= 0 {
+		return 0, 0, 0
+	}
+	if odds == 0 {
+		return 0, 0, 0
+	}
+	return g, odds, 1 + n
+}
+
+// decodeError describes why decodeEntry can't decode the entry at the start of
+// data.
+func decodeError(data []byte, prev int) error {
+	g := Genotype(data[0])
+	switch odds, n := binary.Uvarint(data[1:]); {
+	case g.gene0() == 3 || g.gene1() == 3 || g.gene2() == 3 || g.gene3() == 3:
+		return fmt.Errorf("invalid genotype %#02x", uint8(g))
+	case genotypeToIdx[g] <= prev:
+		return fmt.Errorf("genotype %#02x out of order", uint8(g))
+	case n <= 0:
+		return fmt.Errorf("couldn't decode odds of genotype %#02x", uint8(g))
+	case odds == 0:
+		return fmt.Errorf("genotype %#02x has zero odds", uint8(g))
+	default:
+		return fmt.Errorf("couldn't decode genotype %#02x", uint8(g))
+	}
+}
@@ -0,0 +1,111 @@
+package flower
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SpeciesTableEntry is a single row of a species table, associating a genotype
+// of a named species with its phenotype.
+type SpeciesTableEntry struct {
+	Species   string
+	Genotype  string
+	Phenotype Phenotype
+}
+
+// ReadSpeciesTable reads a species table in CSV format. The first row must be
+// the header "species,genotype,phenotype"; each following row specifies the
+// phenotype of a single genotype of a single species. Lines beginning with '#'
+// are comments, such as a note of the table's source, & are ignored.
+func ReadSpeciesTable(r io.Reader) ([]SpeciesTableEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("couldn't read species table: missing header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read species table: %v", err)
+	}
+	if !strings.EqualFold(header[0], "species") || !strings.EqualFold(header[1], "genotype") || !strings.EqualFold(header[2], "phenotype") {
+		return nil, fmt.Errorf("couldn't read species table: unexpected header %q (expected \"species,genotype,phenotype\")", strings.Join(header, ","))
+	}
+
+	var rslt []SpeciesTableEntry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rslt, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read species table: %v", err)
+		}
+		p, err := ParsePhenotype(rec[2])
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read species table: %s %s: %v", rec[0], rec[1], err)
+		}
+		rslt = append(rslt, SpeciesTableEntry{Species: rec[0], Genotype: rec[1], Phenotype: p})
+	}
+}
+
+// SpeciesTableMismatch describes a way in which a species table disagrees with
+// the built-in species.
+type SpeciesTableMismatch struct {
+	Species  string
+	Genotype string // empty if the mismatch concerns the species as a whole
+	Problem  string
+}
+
+func (m SpeciesTableMismatch) String() string {
+	if m.Genotype == "" {
+		return fmt.Sprintf("%s: %s", m.Species, m.Problem)
+	}
+	return fmt.Sprintf("%s %s: %s", m.Species, m.Genotype, m.Problem)
+}
+
+// VerifySpeciesTable compares a species table against the built-in species,
+// returning all mismatches found. Only species mentioned in the table are
+// checked, but each such species must be fully specified by the table.
+func VerifySpeciesTable(entries []SpeciesTableEntry) []SpeciesTableMismatch {
+	var rslt []SpeciesTableMismatch
+	var species []Species
+	seen := map[string]map[Genotype]bool{}
+	for _, e := range entries {
+		s, ok := SpeciesByName(e.Species)
+		if !ok {
+			rslt = append(rslt, SpeciesTableMismatch{e.Species, e.Genotype, "unknown species"})
+			continue
+		}
+		g, err := s.ParseGenotype(e.Genotype)
+		if err != nil {
+			rslt = append(rslt, SpeciesTableMismatch{s.Name(), e.Genotype, err.Error()})
+			continue
+		}
+		if seen[s.Name()] == nil {
+			seen[s.Name()] = map[Genotype]bool{}
+			species = append(species, s)
+		}
+		if seen[s.Name()][g] {
+			rslt = append(rslt, SpeciesTableMismatch{s.Name(), e.Genotype, "duplicate entry"})
+			continue
+		}
+		seen[s.Name()][g] = true
+		if p := s.Phenotype(g); p != e.Phenotype {
+			rslt = append(rslt, SpeciesTableMismatch{s.Name(), e.Genotype, fmt.Sprintf("table has %v, built-in has %v", s.RenderPhenotype(e.Phenotype), s.RenderPhenotype(p))})
+		}
+	}
+
+	for _, s := range species {
+		for _, g := range s.Genotypes() {
+			if !seen[s.Name()][g] {
+				rslt = append(rslt, SpeciesTableMismatch{s.Name(), s.RenderGenotype(g), "missing from table"})
+			}
+		}
+	}
+	return rslt
+}
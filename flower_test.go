@@ -1,7 +1,11 @@
 package flower
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -29,3 +33,702 @@ func TestGenotypeParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestParseGeneticDistribution(t *testing.T) {
+	s := Roses()
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"RrYyWwSs", "{1:RrYyWwSs}"},
+		{"{1:RrYyWwSs, 3:RRYyWwSs}", "{1:RrYyWwSs, 3:RRYyWwSs}"},
+		{"{2:RrYyWwSs, 6:RRYyWwSs}", "{1:RrYyWwSs, 3:RRYyWwSs}"},
+		{"{ 1 : RrYyWwSs , 3 : RRYyWwSs }", "{1:RrYyWwSs, 3:RRYyWwSs}"},
+		{"Roses:{1:RrYyWwSs, 3:RRYyWwSs}", "{1:RrYyWwSs, 3:RRYyWwSs}"},
+
+		// Percentages, fractions & decimals.
+		{"{RrYyWwSs 50%, RRYYWWSS 25%}", "{2:RrYyWwSs, 1:RRYYWWSS}"},
+		{"{25%:RrYyWwSs, 12.5%:RRYYWWSS}", "{2:RrYyWwSs, 1:RRYYWWSS}"},
+		{"{1/4:rryywwss, 3/4:RRyywwss}", "{1:rryywwss, 3:RRyywwss}"},
+		{"{1.5:RrYyWwSs, 0.5:rryywwss}", "{1:rryywwss, 3:RrYyWwSs}"},
+		{"{1/3:rryywwss, 1:RRyywwss}", "{1:rryywwss, 3:RRyywwss}"},
+
+		// Wildcards & alternatives.
+		{"RrYy??ss", "{1:RrYywwss, 1:RrYyWwss, 1:RrYyWWss}"},
+		{"RrYy__ss 50%", "{1:RrYywwss, 1:RrYyWwss, 1:RrYyWWss}"},
+		{"rryyW_ss", "{1:rryyWwss, 1:rryyWWss}"},
+		{"rryy?wss", "{1:rryywwss, 1:rryyWwss}"},
+		{"(Rr|RR)yyWWss", "{1:RryyWWss, 1:RRyyWWss}"},
+		{"(1:Rr|3:RR)yyWWss", "{1:RryyWWss, 3:RRyyWWss}"},
+		{"(Rr 25%|RR 75%)yyWWss", "{1:RryyWWss, 3:RRyyWWss}"},
+		{"(rr|R?)yyWWss", "{2:rryyWWss, 1:RryyWWss, 1:RRyyWWss}"},
+		{"{1:RrYy??ss, 2:RrYyWwss}", "{1:RrYywwss, 7:RrYyWwss, 1:RrYyWWss}"},
+		{"{RrYy??ss, RrYyW?ss}", "{2:RrYywwss, 5:RrYyWwss, 5:RrYyWWss}"},
+
+		// Shorthands.
+		{"Red from seeds", "{1:RRyyWWSs}"},
+		{"orange FROM Seeds", "{1:RrYyWWss}"},
+		{"Roses:Orange from seeds", "{1:RrYyWWss}"},
+		{"Pink from White x Red seeds", "{1:RryyWwSs, 1:RryyWWSs}"},
+		{"{Orange from seeds 50%, RRYYWWSS 50%}", "{1:RrYyWWss, 1:RRYYWWSS}"},
+	} {
+		gd, err := s.ParseGeneticDistribution(test.in)
+		if err != nil {
+			t.Errorf("ParseGeneticDistribution(%q) got unexpected error: %v", test.in, err)
+			continue
+		}
+		if got := s.RenderGeneticDistribution(gd); got != test.want {
+			t.Errorf("ParseGeneticDistribution(%q) = %s, want %s", test.in, got, test.want)
+		}
+	}
+
+	for _, in := range []string{
+		"", "{}", "{1:RrYyWwSs", "RrYyWwSs}", "{1:RrYyWwSs,}", "{0:RrYyWwSs}", "{:RrYyWwSs}",
+		"{-1:RrYyWwSs}", "{1:RrYyWwSs 50%}", "{1:RrYyWwSs, 1:RrYyWwSs}", "{RrYyWwSs 50%, 1/2:RrYyWwSs}", "{18446744073709551616:RrYyWwSs, 1:rryywwss}",
+		"RrYyWw", "RrYyWwSsTt", "RrYyXxSs", "rRYyWwSs", "(Rr|RR", "(Rr|Xx)YyWwSs", "(0:Rr|RR)YyWwSs",
+		"Blue from seeds", "Pink from seeds", "Pink from Yellow x Yellow seeds", "Teal from seeds",
+		"Tulips:Orange from seeds",
+	} {
+		if gd, err := s.ParseGeneticDistribution(in); err == nil {
+			t.Errorf("ParseGeneticDistribution(%q) = %s, want error", in, s.RenderGeneticDistribution(gd))
+		}
+	}
+
+	// Without a species, shorthands can't be understood unless one is named.
+	if _, err := (GenotypeSerde{}).ParseGeneticDistribution("Orange from seeds"); err == nil {
+		t.Errorf("GenotypeSerde{}.ParseGeneticDistribution(%q) got no error", "Orange from seeds")
+	}
+	gs, err := NewGenotypeSerdeFromExampleDistribution("Tulips:Orange from seeds")
+	if err != nil {
+		t.Fatalf("NewGenotypeSerdeFromExampleDistribution got unexpected error: %v", err)
+	}
+	if got, want := gs.GeneCount(), 3; got != want {
+		t.Errorf("NewGenotypeSerdeFromExampleDistribution(%q).GeneCount() = %d, want %d", "Tulips:Orange from seeds", got, want)
+	}
+}
+
+func TestSpeciesMatchReferenceTable(t *testing.T) {
+	f, err := os.Open("testdata/species.csv")
+	if err != nil {
+		t.Fatalf("Could not open reference table: %v", err)
+	}
+	defer f.Close()
+	entries, err := ReadSpeciesTable(f)
+	if err != nil {
+		t.Fatalf("Could not read reference table: %v", err)
+	}
+
+	for _, m := range VerifySpeciesTable(entries) {
+		t.Errorf("Built-in species disagrees with reference table: %v", m)
+	}
+
+	// Make sure that every built-in species was actually checked.
+	checked := map[string]bool{}
+	for _, e := range entries {
+		checked[e.Species] = true
+	}
+	for _, s := range AllSpecies() {
+		if !checked[s.Name()] {
+			t.Errorf("Reference table does not include species %q", s.Name())
+		}
+	}
+}
+
+func TestVerifySpeciesTableReportsMismatches(t *testing.T) {
+	const table = `# Comments are ignored, both before
+species,genotype,phenotype
+Roses,RRYYwwss,Red
+# & after the header.
+Roses,RRYYwwss,Blue
+Roses,RRYYww,Blue
+Daisies,rryyss,White
+`
+	entries, err := ReadSpeciesTable(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("ReadSpeciesTable got unexpected error: %v", err)
+	}
+	ms := VerifySpeciesTable(entries)
+
+	want := []string{
+		"Roses RRYYwwss: table has Red, built-in has Blue",
+		"Roses RRYYwwss: duplicate entry",
+		`Roses RRYYww: genotype "RRYYww" has wrong length (expected 8)`,
+		"Daisies rryyss: unknown species",
+	}
+	// All 80 other rose genotypes are missing from the table.
+	if len(ms) != len(want)+80 {
+		t.Fatalf("VerifySpeciesTable returned %d mismatches, want %d", len(ms), len(want)+80)
+	}
+	for i, w := range want {
+		if got := ms[i].String(); got != w {
+			t.Errorf("VerifySpeciesTable()[%d] = %q, want %q", i, got, w)
+		}
+	}
+	for _, m := range ms[len(want):] {
+		if m.Problem != "missing from table" {
+			t.Errorf("VerifySpeciesTable got unexpected mismatch %q, want missing genotype", m)
+		}
+	}
+}
+
+func TestPhenotypeGroups(t *testing.T) {
+	for _, s := range AllSpecies() {
+		t.Run(s.Name(), func(t *testing.T) {
+			cnt := 0
+			for _, pg := range s.PhenotypeGroups() {
+				for _, g := range pg.Genotypes {
+					if p := s.Phenotype(g); p != pg.Phenotype {
+						t.Errorf("Genotype %s in group for %v has phenotype %v", s.RenderGenotype(g), pg.Phenotype, p)
+					}
+				}
+				cnt += len(pg.Genotypes)
+			}
+			if want := len(s.Genotypes()); cnt != want {
+				t.Errorf("PhenotypeGroups covers %d genotypes, want %d", cnt, want)
+			}
+		})
+	}
+
+	// Blue roses are famously identifiable by color alone.
+	for _, pg := range Roses().PhenotypeGroups() {
+		if pg.Phenotype == Blue && !pg.Unique() {
+			t.Errorf("Blue roses are not unique: %v", pg.Genotypes)
+		}
+	}
+}
+
+func TestDistinguishingTestCrosses(t *testing.T) {
+	s := Pansies()
+	genotypes := func(strs ...string) []Genotype {
+		var rslt []Genotype
+		for _, str := range strs {
+			g, err := s.ParseGenotype(str)
+			if err != nil {
+				t.Fatalf("Couldn't parse genotype %q: %v", str, err)
+			}
+			rslt = append(rslt, g)
+		}
+		return rslt
+	}
+
+	// Crossing with white (rryyWw) distinguishes all orange pansies except
+	// RRYYWw & RRYYWW, which no seed can tell apart.
+	crosses, indistinguishable := s.DistinguishingTestCrosses(genotypes("RrYyww", "RrYyWw", "RrYyWW", "RRYYWw", "RRYYWW"), s.Seeds())
+	if want := genotypes("rryyWw"); !reflect.DeepEqual(crosses, want) {
+		t.Errorf("DistinguishingTestCrosses got crosses %v, want %v", crosses, want)
+	}
+	if want := [][2]Genotype{{genotypes("RRYYWw")[0], genotypes("RRYYWW")[0]}}; !reflect.DeepEqual(indistinguishable, want) {
+		t.Errorf("DistinguishingTestCrosses got indistinguishable %v, want %v", indistinguishable, want)
+	}
+
+	// Too many testers to search exhaustively: every genotype of white roses
+	// can be told apart using a few of the roses' 81 genotypes.
+	roses := Roses()
+	for _, pg := range roses.PhenotypeGroups() {
+		if pg.Phenotype != White {
+			continue
+		}
+		testers := roses.Genotypes()
+		crosses, indistinguishable := roses.DistinguishingTestCrosses(pg.Genotypes, testers)
+		if len(indistinguishable) != 0 {
+			t.Errorf("DistinguishingTestCrosses got indistinguishable %v, want none", indistinguishable)
+		}
+		if len(crosses) == 0 || len(crosses) > 4 {
+			t.Errorf("DistinguishingTestCrosses got %d crosses, want between 1 & 4", len(crosses))
+		}
+		for i, ga := range pg.Genotypes {
+			for _, gb := range pg.Genotypes[i+1:] {
+				distinguished := false
+				for _, c := range crosses {
+					pa := roses.PhenotypeDistribution(ga.ToGeneticDistribution().Breed(c.ToGeneticDistribution()))
+					pb := roses.PhenotypeDistribution(gb.ToGeneticDistribution().Breed(c.ToGeneticDistribution()))
+					distinguished = distinguished || !samePhenotypeDistribution(pa, pb)
+				}
+				if !distinguished {
+					t.Errorf("DistinguishingTestCrosses got crosses %v, which don't distinguish %s & %s", crosses, roses.RenderGenotype(ga), roses.RenderGenotype(gb))
+				}
+			}
+		}
+	}
+}
+
+func TestRankTestCrosses(t *testing.T) {
+	s := Roses()
+	unknown, err := s.ParseGeneticDistribution("{1:RrYyWWss, 1:RrYYWWss}")
+	if err != nil {
+		t.Fatalf("Couldn't parse genetic distribution: %v", err)
+	}
+	var testers []GeneticDistribution
+	for _, g := range s.Seeds() {
+		testers = append(testers, g.ToGeneticDistribution())
+	}
+
+	tcs := s.RankTestCrosses(unknown, testers, 0.95)
+	if len(tcs) != len(testers) {
+		t.Fatalf("RankTestCrosses returned %d results, want %d", len(tcs), len(testers))
+	}
+
+	// Crossing with the red seed is most informative, since Yy & YY crossed
+	// with yy produce differently-colored offspring. Crossing with the yellow
+	// seed (YY) gives no information at all.
+	if got, want := s.RenderGeneticDistribution(tcs[0].Tester), "{1:RRyyWWSs}"; got != want {
+		t.Errorf("RankTestCrosses best tester = %s, want %s", got, want)
+	}
+	if tcs[0].Offspring <= 0 {
+		t.Errorf("RankTestCrosses best tester needs %d offspring, want positive", tcs[0].Offspring)
+	}
+	last := tcs[len(tcs)-1]
+	if got, want := s.RenderGeneticDistribution(last.Tester), "{1:rrYYWWss}"; got != want {
+		t.Errorf("RankTestCrosses worst tester = %s, want %s", got, want)
+	}
+	if last.InformationGain != 0 || last.Offspring != -1 {
+		t.Errorf("RankTestCrosses worst tester = {gain: %v, offspring: %d}, want {gain: 0, offspring: -1}", last.InformationGain, last.Offspring)
+	}
+}
+
+func TestParentsOf(t *testing.T) {
+	s := Pansies()
+	target, err := s.ParseGenotype("RrYyWW")
+	if err != nil {
+		t.Fatalf("Couldn't parse genotype: %v", err)
+	}
+
+	pairs := s.ParentsOf(target, s.Genotypes())
+	if len(pairs) == 0 {
+		t.Fatalf("ParentsOf returned no parents")
+	}
+	for i, pp := range pairs {
+		// Each probability should match the result of breeding the parents.
+		child := pp.Parents[0].ToGeneticDistribution().Breed(pp.Parents[1].ToGeneticDistribution())
+		var odds, total uint64
+		child.Visit(func(g Genotype, o uint64) bool {
+			total += o
+			if g == target {
+				odds = o
+			}
+			return true
+		})
+		if want := float64(odds) / float64(total); pp.Probability != want {
+			t.Errorf("ParentsOf(%s, %s) has probability %v, want %v", s.RenderGenotype(pp.Parents[0]), s.RenderGenotype(pp.Parents[1]), pp.Probability, want)
+		}
+		if i > 0 && pp.Probability > pairs[i-1].Probability {
+			t.Errorf("ParentsOf results are not ordered by probability at index %d", i)
+		}
+	}
+
+	if pairs[0].Probability != 1 {
+		t.Errorf("ParentsOf best pair has probability %v, want 1", pairs[0].Probability)
+	}
+
+	// Restricted to the seeds, only red & yellow can produce the target, which
+	// they always do.
+	yellow, red := s.Seeds()[1], s.Seeds()[2]
+	if got, want := s.ParentsOf(target, s.Seeds()), []ParentPair{{[2]Genotype{yellow, red}, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParentsOf restricted to seeds = %v, want %v", got, want)
+	}
+
+	// No parents are drawn from an empty set of candidates, such as an
+	// inventory with no uniquely-identifiable genotypes.
+	roses := Roses()
+	inventory := []Genotype{mustParseGenotype(t, roses, "RrYyWwSs"), mustParseGenotype(t, roses, "RrYyWwss")}
+	if got := roses.FilterUnique(inventory); len(got) != 0 {
+		t.Errorf("FilterUnique(%v) = %v, want none", inventory, got)
+	}
+	if got := roses.ParentsOf(inventory[0], roses.FilterUnique(inventory)); len(got) != 0 {
+		t.Errorf("ParentsOf with no candidates = %v, want none", got)
+	}
+	blue := mustParseGenotype(t, roses, "RRYYwwss")
+	if got, want := roses.FilterUnique(append(inventory, blue)), []Genotype{blue}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterUnique(%v) = %v, want %v", append(inventory, blue), got, want)
+	}
+}
+
+func TestPhenotypes(t *testing.T) {
+	for _, s := range AllSpecies() {
+		ps := s.Phenotypes()
+		for i, p := range ps {
+			if p == Unknown {
+				t.Errorf("%s.Phenotypes() includes Unknown", s.Name())
+			}
+			if i > 0 && ps[i-1] >= p {
+				t.Errorf("%s.Phenotypes() = %v, want sorted & unique", s.Name(), ps)
+			}
+		}
+	}
+}
+
+func TestParsePhenotype(t *testing.T) {
+	for p := White; p <= Black; p++ {
+		for _, name := range []string{p.String(), strings.ToLower(p.String()), strings.ToUpper(p.String())} {
+			if got, err := ParsePhenotype(name); err != nil || got != p {
+				t.Errorf("ParsePhenotype(%q) = (%v, %v), want %v", name, got, err, p)
+			}
+		}
+	}
+	for _, name := range []string{"", "Unknown", "Chocolate", "Re d"} {
+		if p, err := ParsePhenotype(name); err == nil {
+			t.Errorf("ParsePhenotype(%q) = %v, want error", name, p)
+		}
+	}
+}
+
+func TestSpeciesPhenotypeInfo(t *testing.T) {
+	// Chocolate cosmos are black cosmos, under a species-specific alias &
+	// color.
+	if got, err := Cosmos().ParsePhenotype("chocolate"); err != nil || got != Black {
+		t.Errorf("Cosmos().ParsePhenotype(%q) = (%v, %v), want %v", "chocolate", got, err, Black)
+	}
+	if got := Cosmos().PhenotypeInfo(Black); got.Name != "Black" || got.Color == Black.Color() || !reflect.DeepEqual(got.Aliases, []string{"Chocolate"}) {
+		t.Errorf("Cosmos().PhenotypeInfo(Black) = %+v, want a chocolate-colored Black", got)
+	}
+	if got, want := Roses().PhenotypeInfo(Black), Black.Info(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Roses().PhenotypeInfo(Black) = %+v, want %+v", got, want)
+	}
+
+	// Species only parse their own phenotypes.
+	if got, err := Roses().ParsePhenotype("blue"); err != nil || got != Blue {
+		t.Errorf("Roses().ParsePhenotype(%q) = (%v, %v), want %v", "blue", got, err, Blue)
+	}
+	for _, name := range []string{"Chocolate", "Green"} {
+		if p, err := Roses().ParsePhenotype(name); err == nil {
+			t.Errorf("Roses().ParsePhenotype(%q) = %v, want error", name, p)
+		}
+	}
+}
+
+func TestPhenotypeInfo(t *testing.T) {
+	if got, want := AllPhenotypes(), []Phenotype{White, Pink, Red, Orange, Yellow, Green, Blue, Purple, Black}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllPhenotypes() = %v, want %v", got, want)
+	}
+	if got := Orange.Info(); got.Name != "Orange" || got.Color != Orange.Color() || !colorRe.MatchString(got.Color) {
+		t.Errorf("Orange.Info() = %+v, want an orange phenotype named Orange", got)
+	}
+	for _, p := range []Phenotype{Unknown, firstSpeciesPhenotype} {
+		if got := p.Info(); got.Name != "" {
+			t.Errorf("Phenotype(%d).Info() = %+v, want no name", p, got)
+		}
+	}
+}
+
+func TestNewSpecies(t *testing.T) {
+	// Each built-in species can be recreated from its definition.
+	for _, s := range AllSpecies() {
+		got, err := NewSpecies(s.Spec())
+		if err != nil {
+			t.Errorf("NewSpecies(%s.Spec()) got unexpected error: %v", s.Name(), err)
+			continue
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("NewSpecies(%s.Spec()) = %+v, want %+v", s.Name(), got, s)
+		}
+	}
+
+	// A species may add its own phenotypes, & rename built-in ones.
+	spec := Roses().Spec()
+	spec.Name = "Golden Roses"
+	spec.Phenotypes["RRYYWWSS"] = "Gilded"
+	spec.Phenotypes["RRYYWWSs"] = "Gold"
+	spec.Phenotypes["RRYYwwss"] = "Azure"
+	spec.PhenotypeSpecs = []PhenotypeSpec{
+		{"", PhenotypeInfo{Name: "Gold", Aliases: []string{"Gilded"}, Color: "#d4af37"}},
+		{"Blue", PhenotypeInfo{Name: "Azure", Aliases: []string{"Sky"}}},
+	}
+	s, err := NewSpecies(spec)
+	if err != nil {
+		t.Fatalf("NewSpecies got unexpected error: %v", err)
+	}
+	gold := s.Phenotype(mustParseGenotype(t, s, "RRYYWWSS"))
+	if gold < firstSpeciesPhenotype || s.Phenotype(mustParseGenotype(t, s, "RRYYWWSs")) != gold {
+		t.Errorf("Phenotype(RRYYWWSS) = %v, want a species-specific phenotype shared with RRYYWWSs", gold)
+	}
+	if got := s.PhenotypeInfo(gold); got.Name != "Gold" || got.Color != "#d4af37" || !reflect.DeepEqual(got.Aliases, []string{"Gilded"}) {
+		t.Errorf("PhenotypeInfo(gold) = %+v, want a gold-colored Gold", got)
+	}
+	if got := s.PhenotypeInfo(Blue); got.Name != "Azure" || got.Color != Blue.Color() || !reflect.DeepEqual(got.Aliases, []string{"Sky"}) {
+		t.Errorf("PhenotypeInfo(Blue) = %+v, want a blue-colored Azure", got)
+	}
+	for name, want := range map[string]Phenotype{"gold": gold, "GILDED": gold, "azure": Blue, "sky": Blue, "blue": Blue, "Red": Red} {
+		if got, err := s.ParsePhenotype(name); err != nil || got != want {
+			t.Errorf("ParsePhenotype(%q) = (%v, %v), want %v", name, got, err, want)
+		}
+	}
+	if got, want := s.RenderPhenotype(gold)+","+s.RenderPhenotype(Blue)+","+s.RenderPhenotype(Red), "Gold,Azure,Red"; got != want {
+		t.Errorf("RenderPhenotype = %q, want %q", got, want)
+	}
+	if got, err := NewSpecies(s.Spec()); err != nil || !reflect.DeepEqual(got, s) {
+		t.Errorf("NewSpecies(%s.Spec()) = (%+v, %v), want %+v", s.Name(), got, err, s)
+	}
+
+	// Phenotypes specific to one species mean nothing to others.
+	if _, err := ParsePhenotype("Gold"); err == nil {
+		t.Errorf("ParsePhenotype(%q) succeeded, want error", "Gold")
+	}
+	if got := Roses().RenderPhenotype(gold); got == "Gold" {
+		t.Errorf("Roses().RenderPhenotype(gold) = %q, want an unnamed phenotype", got)
+	}
+	if got := Roses().RenderPhenotype(Blue); got != "Blue" {
+		t.Errorf("Roses().RenderPhenotype(Blue) = %q, want %q", got, "Blue")
+	}
+
+	// Species-specific names & aliases must be unambiguous, & added
+	// phenotypes need a name & color.
+	for _, specs := range [][]PhenotypeSpec{
+		{{"Black", PhenotypeInfo{Aliases: []string{"Red"}}}},
+		{{"Black", PhenotypeInfo{Name: "Red"}}},
+		{{"Black", PhenotypeInfo{Aliases: []string{"Dark"}}}, {"Purple", PhenotypeInfo{Aliases: []string{"dark"}}}},
+		{{"Black", PhenotypeInfo{Aliases: []string{"Dark", "DARK"}}}},
+		{{"Black", PhenotypeInfo{Aliases: []string{"Dark"}}}, {"Black", PhenotypeInfo{Aliases: []string{"Darker"}}}},
+		{{"Black", PhenotypeInfo{Aliases: []string{" Dark"}}}},
+		{{"Black", PhenotypeInfo{Color: "black"}}},
+		{{"Teal", PhenotypeInfo{Aliases: []string{"Dark"}}}},
+		{{"Green", PhenotypeInfo{Aliases: []string{"Leafy"}}}},
+		{{"", PhenotypeInfo{Name: "Silver"}}},
+		{{"", PhenotypeInfo{Color: "#c0c0c0"}}},
+		{{"", PhenotypeInfo{Name: "red", Color: "#c0c0c0"}}},
+		{{"", PhenotypeInfo{Name: "Silver", Aliases: []string{"silver"}, Color: "#c0c0c0"}}},
+	} {
+		spec := Roses().Spec()
+		spec.PhenotypeSpecs = specs
+		if _, err := NewSpecies(spec); err == nil {
+			t.Errorf("NewSpecies with phenotype specs %+v succeeded, want error", specs)
+		}
+	}
+}
+
+func mustParseGenotype(t *testing.T, s Species, genotype string) Genotype {
+	t.Helper()
+	g, err := s.ParseGenotype(genotype)
+	if err != nil {
+		t.Fatalf("Couldn't parse genotype %q: %v", genotype, err)
+	}
+	return g
+}
+
+func TestPhenotypeText(t *testing.T) {
+	type config struct {
+		Phenotype  Phenotype
+		Phenotypes []Phenotype
+		Odds       map[Phenotype]int
+	}
+	want := config{Orange, []Phenotype{White, Black}, map[Phenotype]int{Red: 1, Yellow: 2}}
+	buf, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("json.Marshal got unexpected error: %v", err)
+	}
+	if got, want := string(buf), `{"Phenotype":"Orange","Phenotypes":["White","Black"],"Odds":{"Red":1,"Yellow":2}}`; got != want {
+		t.Errorf("json.Marshal = %s, want %s", got, want)
+	}
+	var got config
+	if err := json.Unmarshal([]byte(`{"Phenotype":"orange","Phenotypes":["WHITE","Black"],"Odds":{"red":1,"Yellow":2}}`), &got); err != nil {
+		t.Fatalf("json.Unmarshal got unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json.Unmarshal = %+v, want %+v", got, want)
+	}
+
+	if _, err := Unknown.MarshalText(); err == nil {
+		t.Errorf("Unknown.MarshalText() got no error")
+	}
+	var p Phenotype
+	if err := p.UnmarshalText([]byte("Teal")); err == nil {
+		t.Errorf("UnmarshalText(%q) = %v, want error", "Teal", p)
+	}
+}
+
+func TestMarginal(t *testing.T) {
+	s := Roses()
+	var gds []GeneticDistribution
+	for _, g := range s.Seeds() {
+		gds = append(gds, g.ToGeneticDistribution())
+	}
+	gds = append(gds, gds[0].Breed(gds[1]), gds[1].Breed(gds[2]))
+
+	for _, genes := range [][]int{{0}, {1, 3}, {0, 1, 2, 3}} {
+		for _, gda := range gds {
+			for _, gdb := range gds {
+				// Breeding should commute with taking marginals.
+				want := gda.Breed(gdb).Marginal(genes...)
+				if got := gda.Marginal(genes...).Breed(gdb.Marginal(genes...)); got != want {
+					t.Errorf("Marginal(%v) of parents bred = %s, want %s", genes, s.RenderGeneticDistribution(got), s.RenderGeneticDistribution(want))
+				}
+				want.Visit(func(g Genotype, _ uint64) bool {
+					for i := 0; i < 4; i++ {
+						if g>>(2*i)&0b11 != 0 && !containsInt(genes, i) {
+							t.Errorf("Marginal(%v) includes genotype %s", genes, s.RenderGenotype(g))
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+}
+
+func TestRelevantGenes(t *testing.T) {
+	s := Roses()
+	for _, test := range []struct {
+		desc string
+		pred func(Genotype) bool
+		want []int
+	}{
+		{"constant", func(Genotype) bool { return true }, nil},
+		{"second gene dominant", func(g Genotype) bool { return g>>2&0b11 != 0 }, []int{1}},
+		{"first or last gene recessive", func(g Genotype) bool { return g&0b11 == 0 || g>>6 == 0 }, []int{0, 3}},
+	} {
+		if got := s.RelevantGenes(test.pred); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("RelevantGenes(%s) = %v, want %v", test.desc, got, test.want)
+		}
+	}
+
+	// Pansies have only three genes, so the fourth is never relevant.
+	if got, want := Pansies().RelevantGenes(func(g Genotype) bool { return g>>6 == 0 }), []int(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("Pansies().RelevantGenes(fourth gene recessive) = %v, want %v", got, want)
+	}
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseTestDistributions returns a variety of rose distributions: the seeds,
+// their children & grandchildren, & the zero distribution.
+func sparseTestDistributions() []GeneticDistribution {
+	var gds []GeneticDistribution
+	for _, g := range Roses().Seeds() {
+		gds = append(gds, g.ToGeneticDistribution())
+	}
+	n := len(gds)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			gds = append(gds, gds[i].Breed(gds[j]))
+		}
+	}
+	gds = append(gds, gds[n].Breed(gds[len(gds)-1]), GeneticDistribution{})
+	return gds
+}
+
+func TestSparseGeneticDistribution(t *testing.T) {
+	s := Roses()
+	gds := sparseTestDistributions()
+	keepRed := func(g Genotype) bool { return s.Phenotype(g) == Red }
+	for _, gd := range gds {
+		sgd := gd.ToSparse()
+		name := s.RenderGeneticDistribution(gd)
+		if got := sgd.ToGeneticDistribution(); got != gd {
+			t.Errorf("%s round-tripped through sparse form = %s", name, s.RenderGeneticDistribution(got))
+		}
+		if got, want := sgd.IsZero(), gd.IsZero(); got != want {
+			t.Errorf("%s sparse IsZero() = %v, want %v", name, got, want)
+		}
+
+		var got, want []string
+		sgd.Visit(func(g Genotype, odds uint64) bool {
+			got = append(got, fmt.Sprintf("%s:%d", s.RenderGenotype(g), odds))
+			return true
+		})
+		gd.Visit(func(g Genotype, odds uint64) bool {
+			want = append(want, fmt.Sprintf("%s:%d", s.RenderGenotype(g), odds))
+			return true
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s sparse Visit visited %v, want %v", name, got, want)
+		}
+
+		wantFiltered := gd.Update(func(mgd *MutableGeneticDistribution) {
+			gd.Visit(func(g Genotype, _ uint64) bool {
+				if !keepRed(g) {
+					mgd.SetOdds(g, 0)
+				}
+				return true
+			})
+		})
+		if got := sgd.Filter(keepRed); !got.Equal(wantFiltered.ToSparse()) {
+			t.Errorf("%s sparse Filter(red) = %s, want %s", name, s.RenderGeneticDistribution(got.ToGeneticDistribution()), s.RenderGeneticDistribution(wantFiltered))
+		}
+		if got := sgd.Update(func(mgd *MutableGeneticDistribution) { mgd.SetOdds(0, 3) }); got.ToGeneticDistribution() != gd.Update(func(mgd *MutableGeneticDistribution) { mgd.SetOdds(0, 3) }) {
+			t.Errorf("%s sparse Update = %s", name, s.RenderGeneticDistribution(got.ToGeneticDistribution()))
+		}
+
+		for _, gdb := range gds {
+			want := gd.Breed(gdb)
+			if got := sgd.Breed(gdb.ToSparse()); !got.Equal(want.ToSparse()) {
+				t.Errorf("%s sparse Breed(%s) = %s, want %s", name, s.RenderGeneticDistribution(gdb), s.RenderGeneticDistribution(got.ToGeneticDistribution()), s.RenderGeneticDistribution(want))
+			}
+		}
+	}
+}
+
+func TestSparseGeneticDistributionBinary(t *testing.T) {
+	s := Roses()
+	for _, gd := range sparseTestDistributions() {
+		data, err := gd.ToSparse().MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		var got SparseGeneticDistribution
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Errorf("UnmarshalBinary(MarshalBinary(%s)) failed: %v", s.RenderGeneticDistribution(gd), err)
+		} else if !got.Equal(gd.ToSparse()) {
+			t.Errorf("UnmarshalBinary(MarshalBinary(%s)) = %s", s.RenderGeneticDistribution(gd), s.RenderGeneticDistribution(got.ToGeneticDistribution()))
+		}
+	}
+
+	for _, data := range [][]byte{
+		{0x03, 1},          // invalid genotype
+		{0x00, 1, 0x00, 1}, // repeated genotype
+		{0x01, 1, 0x00, 1}, // genotypes out of order
+		{0x00, 0},          // zero odds
+		{0x00},             // missing odds
+		{0x00, 0x80},       // truncated odds
+	} {
+		var sgd SparseGeneticDistribution
+		if err := sgd.UnmarshalBinary(data); err == nil {
+			t.Errorf("UnmarshalBinary(%v) succeeded", data)
+		}
+	}
+}
+
+func BenchmarkBreed(b *testing.B) {
+	gds := sparseTestDistributions()
+	gds = gds[:len(gds)-1] // skip the zero distribution
+	b.Run("dense", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gds[i%len(gds)].Breed(gds[(i/len(gds))%len(gds)])
+		}
+	})
+	b.Run("sparse", func(b *testing.B) {
+		sgds := make([]SparseGeneticDistribution, len(gds))
+		for i, gd := range gds {
+			sgds[i] = gd.ToSparse()
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			sgds[i%len(sgds)].Breed(sgds[(i/len(sgds))%len(sgds)])
+		}
+	})
+}
+
+func BenchmarkVisit(b *testing.B) {
+	gds := sparseTestDistributions()
+	var total uint64
+	visit := func(_ Genotype, odds uint64) bool { total += odds; return true }
+	b.Run("dense", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gds[i%len(gds)].Visit(visit)
+		}
+	})
+	b.Run("sparse", func(b *testing.B) {
+		sgds := make([]SparseGeneticDistribution, len(gds))
+		for i, gd := range gds {
+			sgds[i] = gd.ToSparse()
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			sgds[i%len(sgds)].Visit(visit)
+		}
+	})
+}
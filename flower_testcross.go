@@ -0,0 +1,174 @@
+package flower
+
+import (
+	"math"
+	"sort"
+)
+
+// MaxTestCrossOffspring is the largest number of offspring considered when
+// estimating how many offspring of a test cross are needed to identify a
+// flower's genotype.
+const MaxTestCrossOffspring = 100
+
+// TestCross describes how well breeding with a given tester flower identifies
+// the genotype of an unknown flower.
+type TestCross struct {
+	Tester GeneticDistribution
+
+	// InformationGain is the expected information, in bits, gained about the
+	// unknown flower's genotype by observing the phenotype of a single
+	// offspring.
+	InformationGain float64
+
+	// Offspring is an estimate of the number of offspring whose phenotypes
+	// must be observed to identify the unknown flower's genotype with the
+	// requested confidence, or -1 if that confidence can't be reached within
+	// MaxTestCrossOffspring offspring.
+	Offspring int
+}
+
+// RankTestCrosses determines, for each of the given testers, how well breeding
+// the tester with a flower of unknown genotype (whose possible genotypes are
+// described by unknown) identifies the unknown flower's genotype. The results
+// are ordered from most to least informative.
+//
+// Offspring counts are estimated via the Bhattacharyya bound on the error of
+// the most-likely-genotype guess, so they are conservative: after that many
+// offspring, the guess is correct with probability at least confidence.
+// Offspring are assumed independent given the unknown flower's genotype, which
+// is exact only for testers with a single possible genotype.
+func (s Species) RankTestCrosses(unknown GeneticDistribution, testers []GeneticDistribution, confidence float64) []TestCross {
+	// Determine the prior distribution of the unknown flower's genotype.
+	var genotypes []Genotype
+	var prior []float64
+	var total float64
+	unknown.Visit(func(g Genotype, odds uint64) bool {
+		genotypes = append(genotypes, g)
+		prior = append(prior, float64(odds))
+		total += float64(odds)
+		return true
+	})
+	for i := range prior {
+		prior[i] /= total
+	}
+
+	rslt := make([]TestCross, len(testers))
+	for i, tester := range testers {
+		// Determine the offspring phenotype distribution for each possible
+		// genotype of the unknown flower. likelihoods[j][k] is the probability
+		// of an offspring having phenotype k, given genotype j.
+		pds := make([]map[Phenotype]uint64, len(genotypes))
+		var phenotypes []Phenotype
+		for j, g := range genotypes {
+			pds[j] = s.PhenotypeDistribution(g.ToGeneticDistribution().Breed(tester))
+			for p := range pds[j] {
+				phenotypes = append(phenotypes, p)
+			}
+		}
+		phenotypes = sortedUniquePhenotypes(phenotypes)
+		likelihoods := make([][]float64, len(genotypes))
+		for j, pd := range pds {
+			var total uint64
+			for _, odds := range pd {
+				total += odds
+			}
+			likelihoods[j] = make([]float64, len(phenotypes))
+			for k, p := range phenotypes {
+				likelihoods[j][k] = float64(pd[p]) / float64(total)
+			}
+		}
+
+		rslt[i] = TestCross{
+			Tester:          tester,
+			InformationGain: informationGain(prior, likelihoods),
+			Offspring:       offspringNeeded(prior, likelihoods, confidence),
+		}
+	}
+
+	sort.SliceStable(rslt, func(i, j int) bool {
+		if rslt[i].InformationGain != rslt[j].InformationGain {
+			return rslt[i].InformationGain > rslt[j].InformationGain
+		}
+		// Prefer test crosses that can reach the requested confidence, & that
+		// do so with fewer offspring.
+		oi, oj := rslt[i].Offspring, rslt[j].Offspring
+		return oi != -1 && (oj == -1 || oi < oj)
+	})
+	return rslt
+}
+
+func sortedUniquePhenotypes(ps []Phenotype) []Phenotype {
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	rslt := ps[:0]
+	for i, p := range ps {
+		if i == 0 || p != ps[i-1] {
+			rslt = append(rslt, p)
+		}
+	}
+	return rslt
+}
+
+// informationGain computes the mutual information between the genotype
+// (distributed according to prior) & the phenotype of a single offspring.
+func informationGain(prior []float64, likelihoods [][]float64) float64 {
+	if len(likelihoods) == 0 {
+		return 0
+	}
+	marginal := make([]float64, len(likelihoods[0]))
+	for i, l := range likelihoods {
+		for k, q := range l {
+			marginal[k] += prior[i] * q
+		}
+	}
+	var rslt float64
+	for i, l := range likelihoods {
+		for k, q := range l {
+			if q != 0 {
+				rslt += prior[i] * q * math.Log2(q/marginal[k])
+			}
+		}
+	}
+	if rslt < 0 {
+		// Avoid reporting tiny negative values due to rounding.
+		rslt = 0
+	}
+	return rslt
+}
+
+// offspringNeeded estimates the number of offspring needed to correctly guess
+// the genotype with the given confidence, or -1 if the confidence can't be
+// reached within MaxTestCrossOffspring offspring.
+func offspringNeeded(prior []float64, likelihoods [][]float64, confidence float64) int {
+	// The probability of error after n offspring is bounded by
+	//   sum_{i<j} sqrt(prior_i * prior_j) * BC(i, j)^n
+	// where BC(i, j) is the Bhattacharyya coefficient between the offspring
+	// phenotype distributions of genotypes i & j.
+	type term struct{ weight, bc float64 }
+	var terms []term
+	for i := range likelihoods {
+		for j := i + 1; j < len(likelihoods); j++ {
+			var bc float64
+			for k, qi := range likelihoods[i] {
+				bc += math.Sqrt(qi * likelihoods[j][k])
+			}
+			if bc > 1 {
+				// Identical distributions may produce values slightly above 1
+				// due to rounding.
+				bc = 1
+			}
+			terms = append(terms, term{math.Sqrt(prior[i] * prior[j]), bc})
+		}
+	}
+
+	maxErr := 1 - confidence
+	for n := 0; n <= MaxTestCrossOffspring; n++ {
+		var errBound float64
+		for _, t := range terms {
+			errBound += t.weight * math.Pow(t.bc, float64(n))
+		}
+		if errBound <= maxErr {
+			return n
+		}
+	}
+	return -1
+}
@@ -1,30 +1,151 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/exec"
+	"sort"
+	"strings"
 
 	"github.com/BranLwyd/acnh_flowers/breedgraph"
 	"github.com/BranLwyd/acnh_flowers/flower"
+	"github.com/BranLwyd/acnh_flowers/render"
 )
 
 const (
 	expandSteps = 4
 )
 
+// commands maps subcommand names to their implementations. Each command is
+// passed the command-line arguments following the subcommand name.
+var commands = map[string]func(args []string) error{
+	"analyze-species": analyzeSpeciesCommand,
+	"breed":           breedCommand,
+	"expand-worker":   expandWorkerCommand,
+	"parents":         parentsCommand,
+	"plan":            planCommand,
+	"repl":            replCommand,
+	"test-cross":      testCrossCommand,
+	"verify-species":  verifySpeciesCommand,
+}
+
 func main() {
+	// With no arguments, default to planning (this tool's original behavior).
+	cmd, args := "plan", []string(nil)
+	if len(os.Args) > 1 {
+		cmd, args = os.Args[1], os.Args[2:]
+	}
+	run, ok := commands[cmd]
+	if !ok {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "Unknown command %q (known commands: %s).\n", cmd, strings.Join(names, ", "))
+		os.Exit(2)
+	}
+	if err := run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+		os.Exit(1)
+	}
+}
+
+func planCommand(args []string) (err error) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	format := fs.String("format", "dot", fmt.Sprintf("The output format; one of: %s.", strings.Join(render.Formats(), ", ")))
+	full := fs.Bool("full", false, "If set, render the entire breeding graph rather than only the path to the result.")
+	prune := fs.Bool("prune", false, "If set, work backward from the target to discard flowers which can't lead to it. This finds an equally good plan, but saves little work for targets with many possible ancestors, such as blue roses.")
+	var policyNames []string
+	for _, p := range breedgraph.Policies() {
+		policyNames = append(policyNames, p.Name())
+	}
+	policyName := fs.String("policy", breedgraph.MinCostPolicy.Name(), fmt.Sprintf("The policy used to choose between plans; one of: %s.", strings.Join(policyNames, ", ")))
+	workerCnt := fs.Int("workers", 0, "The number of worker processes across which to shard the breeding graph's expansion; if 0, it is expanded in this process.")
+	fs.Parse(args)
+
+	policy, ok := breedgraph.PolicyByName(*policyName)
+	if !ok {
+		return fmt.Errorf("unknown policy %q (known policies: %s)", *policyName, strings.Join(policyNames, ", "))
+	}
+
 	// Initial flowers.
 	roses := flower.Roses()
-	seedWhite := must(roses.ParseGenotype("rryyWwss")).ToGeneticDistribution()
-	seedYellow := must(roses.ParseGenotype("rrYYWWss")).ToGeneticDistribution()
-	seedRed := must(roses.ParseGenotype("RRyyWWSs")).ToGeneticDistribution()
 	blueRoseGeno := must(roses.ParseGenotype("RRYYwwss"))
-	blueRose := blueRoseGeno.ToGeneticDistribution()
+	var seeds []flower.GeneticDistribution
+	for _, g := range roses.Seeds() {
+		seeds = append(seeds, g.ToGeneticDistribution())
+	}
+
+	names := seedNames(roses)
+	names[blueRoseGeno.ToGeneticDistribution()] = "Blue Roses (RRYYwwss)"
+	r, err := render.New(*format, roses, names)
+	if err != nil {
+		return err
+	}
 
+	var workers *breedgraph.Workers
+	if *workerCnt > 0 {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("couldn't find executable for workers: %v", err)
+		}
+		cmds := make([]*exec.Cmd, *workerCnt)
+		for i := range cmds {
+			cmds[i] = exec.Command(exe, "expand-worker")
+			cmds[i].Stderr = os.Stderr
+		}
+		if workers, err = breedgraph.StartWorkers(cmds); err != nil {
+			return err
+		}
+	}
+	if workers != nil {
+		defer func() {
+			// A failed expansion has already been reported, & also causes
+			// Close to fail.
+			if closeErr := workers.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("couldn't stop workers: %v", closeErr)
+			}
+		}()
+	}
+
+	candidate, g, err := plan(roses, seeds, blueRoseGeno, planOptions{expandSteps, *prune, policy, workers})
+	if err != nil {
+		return err
+	}
+
+	// Print result.
+	var sg render.Subgraph = render.Path(g, candidate)
+	if *full {
+		sg = g
+	}
+	w := bufio.NewWriter(os.Stdout)
+	if err := r.Render(w, sg); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// planOptions controls how plan searches for a plan.
+type planOptions struct {
+	steps   int                 // the number of times to expand the breeding graph
+	prune   bool                // if set, the search is pruned using breedgraph.Graph.Plan
+	policy  *breedgraph.Policy  // the policy used to choose between plans
+	workers *breedgraph.Workers // if set, the worker processes used to expand the breeding graph
+}
+
+// plan searches for the best way, according to the policy, to breed a flower of
+// the target genotype from the given initial flowers. It returns the vertex for
+// the target & the breeding graph.
+func plan(s flower.Species, initial []flower.GeneticDistribution, target flower.Genotype, opts planOptions) (breedgraph.Vertex, *breedgraph.Graph, error) {
+	steps := opts.steps
 	candidatePredicate := func(gd flower.GeneticDistribution) bool {
 		isSuitable := true
 		gd.Visit(func(g flower.Genotype, _ uint64) bool {
-			if g != blueRoseGeno {
+			if g != target {
 				isSuitable = false
 			}
 			return isSuitable
@@ -34,112 +155,62 @@ func main() {
 
 	// Breeding tests.
 	tests := []*breedgraph.Test{breedgraph.NoTest}
-	tests = append(tests, breedgraph.PhenotypeTestsUpToSize(roses, 1)...)
-
-	g := breedgraph.NewGraph(tests, []flower.GeneticDistribution{seedWhite, seedYellow, seedRed})
-	for i := 0; i < expandSteps; i++ {
+	tests = append(tests, breedgraph.PhenotypeTestsUpToSize(s, 1)...)
+
+	g := breedgraph.NewGraph(tests, initial)
+	g.SetPolicy(opts.policy)
+	fmt.Fprintf(os.Stderr, "Choosing plans by the %s policy: %s.\n", opts.policy.Name(), opts.policy.Description())
+	if opts.prune {
+		fmt.Fprintf(os.Stderr, "Searching up to %d steps, discarding flowers which can't lead to the target...\n", steps)
+		var candidate breedgraph.Vertex
+		var ok bool
+		if opts.workers != nil {
+			var err error
+			if candidate, ok, err = g.PlanWith(opts.workers, s, target, steps); err != nil {
+				return breedgraph.Vertex{}, nil, err
+			}
+		} else {
+			candidate, ok = g.Plan(s, target, steps)
+		}
+		if !ok {
+			return breedgraph.Vertex{}, nil, errors.New("no solution possible")
+		}
+		return candidate, g, nil
+	}
+	for i := 0; i < steps; i++ {
 		fmt.Fprintf(os.Stderr, "Beginning graph expansion step %d...\n", i+1)
 		keepPred := func(flower.GeneticDistribution) bool { return true }
-		if i == expandSteps-1 {
+		if i == steps-1 {
 			// On the last step, keep only if it's a solution
 			// candidate, since we won't be expanding any more from
 			// it.
 			keepPred = candidatePredicate
 		}
+		if opts.workers != nil {
+			if err := g.ExpandWith(opts.workers, keepPred); err != nil {
+				return breedgraph.Vertex{}, nil, err
+			}
+			continue
+		}
 		g.Expand(keepPred)
 	}
 
 	// Find candidate distribution, or fail out if this is impossible.
 	candidate, ok := g.Search(candidatePredicate)
 	if !ok {
-		fmt.Fprintf(os.Stderr, "No solution possible.\n")
-		os.Exit(1)
+		return breedgraph.Vertex{}, nil, errors.New("no solution possible")
 	}
-
-	// Print result.
-	names := map[flower.GeneticDistribution]string{}
-	names[seedWhite] = "Seed White (rryyWwss)"
-	names[seedYellow] = "Seed Yellow (rrYYWWss)"
-	names[seedRed] = "Seed Red (RRyyWWSs)"
-	names[blueRose] = "Blue Roses (RRYYwwss)"
-	printDotGraphPathTo(roses, candidate, names)
-}
-
-func printGraph(s flower.Species, g *breedgraph.Graph, names map[flower.GeneticDistribution]string) {
-	name := func(gd flower.GeneticDistribution) string {
-		if name, ok := names[gd]; ok {
-			return name
-		}
-		name := s.RenderGeneticDistribution(gd)
-		names[gd] = name
-		return name
-	}
-
-	fmt.Println("All flowers:")
-	g.VisitVertices(func(v breedgraph.Vertex) {
-		fmt.Printf("  %s\n", name(v.Value()))
-	})
-
-	fmt.Println("Lineage:")
-	g.VisitEdges(func(e breedgraph.Edge) {
-		fmt.Printf("  %s and %s make %s [test = %q, cost = %.02f]\n", name(e.FirstParent().Value()), name(e.SecondParent().Value()), name(e.Child().Value()), e.Test().Name(), e.EdgeCost())
-	})
-
-}
-
-func printDotGraph(s flower.Species, g *breedgraph.Graph, names map[flower.GeneticDistribution]string) {
-	name := func(gd flower.GeneticDistribution) string {
-		if name, ok := names[gd]; ok {
-			return name
-		}
-		name := s.RenderGeneticDistribution(gd)
-		names[gd] = name
-		return name
-	}
-
-	// Print vertices.
-	fmt.Println("digraph {")
-	g.VisitVertices(func(v breedgraph.Vertex) {
-		fmt.Printf(`  "%s"`, name(v.Value()))
-		fmt.Println()
-	})
-	fmt.Println()
-
-	// Print edges.
-	g.VisitEdges(func(e breedgraph.Edge) {
-		fmt.Printf(`  {"%s" "%s"} -> "%s" [label="%s"]`, name(e.FirstParent().Value()), name(e.SecondParent().Value()), name(e.Child().Value()), edgeLabel(e.Test().Name(), e.EdgeCost()))
-		fmt.Println()
-	})
-	fmt.Println("}")
-}
-
-func printDotGraphPathTo(s flower.Species, v breedgraph.Vertex, names map[flower.GeneticDistribution]string) {
-	name := func(gd flower.GeneticDistribution) string {
-		if name, ok := names[gd]; ok {
-			return name
-		}
-		name := s.RenderGeneticDistribution(gd)
-		names[gd] = name
-		return name
-	}
-
-	// Print vertices.
-	fmt.Println("digraph {")
-	v.VisitPathTo(func(v breedgraph.Vertex) {
-		fmt.Printf(`  "%s"`, name(v.Value()))
-		fmt.Println()
-	}, func(e breedgraph.Edge) {
-		fmt.Printf(`  {"%s" "%s"} -> "%s" [label="%s"]`, name(e.FirstParent().Value()), name(e.SecondParent().Value()), name(e.Child().Value()), edgeLabel(e.Test().Name(), e.EdgeCost()))
-		fmt.Println()
-	})
-	fmt.Println("}")
+	return candidate, g, nil
 }
 
-func edgeLabel(test string, cost float64) string {
-	if test != "" {
-		return fmt.Sprintf("%s (%.2f)", test, cost)
+// seedNames returns human-readable names for the seed flowers of a species,
+// such as "Seed White (rryyWwss)".
+func seedNames(s flower.Species) map[flower.GeneticDistribution]string {
+	rslt := map[flower.GeneticDistribution]string{}
+	for _, g := range s.Seeds() {
+		rslt[g.ToGeneticDistribution()] = fmt.Sprintf("Seed %s (%s)", s.RenderPhenotype(s.Phenotype(g)), s.RenderGenotype(g))
 	}
-	return fmt.Sprintf("%.02f", cost)
+	return rslt
 }
 
 func must(g flower.Genotype, err error) flower.Genotype {
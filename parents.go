@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// parentsCommand lists the pairs of parents able to produce a given genotype.
+func parentsCommand(args []string) error {
+	fs := flag.NewFlagSet("parents", flag.ExitOnError)
+	speciesName := fs.String("species", "Roses", "The species of flower to consider.")
+	unique := fs.Bool("unique", false, "If set, consider only parents which can be identified by phenotype alone.")
+	limit := fs.Int("n", 20, "The maximum number of parent pairs to list; 0 lists all of them.")
+	var inventoryStrs stringsFlag
+	fs.Var(&inventoryStrs, "inventory", "A genotype available to use as a parent. May be repeated. If unspecified, all genotypes are considered.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s parents [flags] <genotype>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, ok := flower.SpeciesByName(*speciesName)
+	if !ok {
+		return fmt.Errorf("unknown species %q", *speciesName)
+	}
+	target, err := s.ParseGenotype(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	candidates := s.Genotypes()
+	if len(inventoryStrs) > 0 {
+		candidates = nil
+		for _, str := range inventoryStrs {
+			g, err := s.ParseGenotype(str)
+			if err != nil {
+				return fmt.Errorf("couldn't parse inventory: %v", err)
+			}
+			candidates = append(candidates, g)
+		}
+	}
+	if *unique {
+		if candidates = s.FilterUnique(candidates); len(candidates) == 0 {
+			return errors.New("no candidate parent can be identified by phenotype alone")
+		}
+	}
+
+	pairs := s.ParentsOf(target, candidates)
+	if len(pairs) == 0 {
+		return fmt.Errorf("no pair of parents can produce %s", s.RenderGenotype(target))
+	}
+	if *limit > 0 && len(pairs) > *limit {
+		pairs = pairs[:*limit]
+	}
+
+	describe := func(g flower.Genotype) string {
+		return fmt.Sprintf("%s (%s)", s.RenderGenotype(g), s.RenderPhenotype(s.Phenotype(g)))
+	}
+	fmt.Printf("Parents of %s:\n\n", describe(target))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Parent\tParent\tProbability\n")
+	for _, pp := range pairs {
+		fmt.Fprintf(tw, "%s\t%s\t%.2f%%\n", describe(pp.Parents[0]), describe(pp.Parents[1]), 100*pp.Probability)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,160 @@
+// Package render renders breeding graphs, & paths through them, in a variety of
+// formats.
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/BranLwyd/acnh_flowers/breedgraph"
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// Subgraph is a set of vertices & edges of a breeding graph to be rendered,
+// along with the policy by which the graph's paths were chosen. It is
+// implemented by *breedgraph.Graph & by the subgraphs returned by Path.
+type Subgraph interface {
+	VisitVertices(func(breedgraph.Vertex))
+	VisitEdges(func(breedgraph.Edge))
+	Policy() *breedgraph.Policy
+}
+
+// PathSubgraph is the subgraph consisting of the lowest-cost path to a vertex.
+// Its vertices & edges are visited in dependency order, as given by
+// breedgraph.Vertex.VisitPathInOrder.
+type PathSubgraph struct {
+	target breedgraph.Vertex
+	policy *breedgraph.Policy
+}
+
+// Path returns the subgraph consisting of the lowest-cost path to v, a vertex
+// of g.
+func Path(g *breedgraph.Graph, v breedgraph.Vertex) *PathSubgraph {
+	return &PathSubgraph{v, g.Policy()}
+}
+
+func (p *PathSubgraph) Target() breedgraph.Vertex  { return p.target }
+func (p *PathSubgraph) Policy() *breedgraph.Policy { return p.policy }
+
+func (p *PathSubgraph) VisitVertices(f func(breedgraph.Vertex)) {
+	p.target.VisitPathInOrder(func(v breedgraph.Vertex, _ int) { f(v) }, func(breedgraph.Edge, int) {})
+}
+
+func (p *PathSubgraph) VisitEdges(f func(breedgraph.Edge)) {
+	p.target.VisitPathInOrder(func(breedgraph.Vertex, int) {}, func(e breedgraph.Edge, _ int) { f(e) })
+}
+
+// Renderer renders subgraphs in some format.
+type Renderer interface {
+	Render(w io.Writer, sg Subgraph) error
+}
+
+var formats = map[string]func(namer) Renderer{
+	"dot":      func(n namer) Renderer { return dotRenderer{n} },
+	"guide":    func(n namer) Renderer { return guideRenderer{n} },
+	"json":     func(n namer) Renderer { return jsonRenderer{n} },
+	"markdown": func(n namer) Renderer { return markdownRenderer{n} },
+	"mermaid":  func(n namer) Renderer { return mermaidRenderer{n} },
+	"png":      func(n namer) Renderer { return pngRenderer{n} },
+	"svg":      func(n namer) Renderer { return svgRenderer{n} },
+	"text":     func(n namer) Renderer { return textRenderer{n} },
+}
+
+// Formats returns the names of all supported formats, in sorted order.
+func Formats() []string {
+	var rslt []string
+	for f := range formats {
+		rslt = append(rslt, f)
+	}
+	sort.Strings(rslt)
+	return rslt
+}
+
+// New returns a renderer for the given format, which must be one of those
+// returned by Formats. Flowers are rendered using the given names if
+// available, or their genetic distribution otherwise.
+func New(format string, s flower.Species, names map[flower.GeneticDistribution]string) (Renderer, error) {
+	newRenderer, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (known formats: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return newRenderer(namer{s, names}), nil
+}
+
+// namer determines human-readable names for genetic distributions.
+type namer struct {
+	s     flower.Species
+	names map[flower.GeneticDistribution]string
+}
+
+func (n namer) name(gd flower.GeneticDistribution) string {
+	if name, ok := n.names[gd]; ok {
+		return name
+	}
+	return n.s.RenderGeneticDistribution(gd)
+}
+
+func (n namer) hasName(gd flower.GeneticDistribution) bool {
+	_, ok := n.names[gd]
+	return ok
+}
+
+// indexedSubgraph is a subgraph whose vertices have been assigned sequential
+// IDs, in visitation order.
+type indexedSubgraph struct {
+	verts []breedgraph.Vertex
+	ids   map[breedgraph.Vertex]int
+	edges []breedgraph.Edge
+}
+
+func index(sg Subgraph) *indexedSubgraph {
+	is := &indexedSubgraph{ids: map[breedgraph.Vertex]int{}}
+	sg.VisitVertices(func(v breedgraph.Vertex) {
+		is.ids[v] = len(is.verts)
+		is.verts = append(is.verts, v)
+	})
+	sg.VisitEdges(func(e breedgraph.Edge) {
+		is.edges = append(is.edges, e)
+	})
+	return is
+}
+
+func edgeLabel(test string, cost float64) string {
+	if test != "" {
+		return fmt.Sprintf("%s (%.2f)", test, cost)
+	}
+	return fmt.Sprintf("%.02f", cost)
+}
+
+// errWriter wraps an io.Writer, remembering the first error encountered so that
+// a sequence of writes may be checked for errors once at the end.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
+
+// textRenderer renders subgraphs as a plain-text list of flowers & lineage.
+type textRenderer struct{ namer }
+
+func (r textRenderer) Render(w io.Writer, sg Subgraph) error {
+	ew := &errWriter{w: w}
+	ew.printf("All flowers:\n")
+	sg.VisitVertices(func(v breedgraph.Vertex) {
+		ew.printf("  %s\n", r.name(v.Value()))
+	})
+
+	ew.printf("Lineage:\n")
+	sg.VisitEdges(func(e breedgraph.Edge) {
+		ew.printf("  %s and %s make %s [test = %q, cost = %.02f]\n", r.name(e.FirstParent().Value()), r.name(e.SecondParent().Value()), r.name(e.Child().Value()), e.Test().Name(), e.EdgeCost())
+	})
+	return ew.err
+}
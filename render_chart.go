@@ -0,0 +1,392 @@
+package render
+
+import (
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BranLwyd/acnh_flowers/breedgraph"
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// Chart layout parameters, in pixels. Text is laid out assuming a monospace
+// font with glyphs of size fontWidth x fontHeight.
+const (
+	chartMargin       = 20
+	chartNodePadX     = 10
+	chartNodePadY     = 8
+	chartNodeHeight   = fontHeight + 2*chartNodePadY
+	chartNodeGapY     = 30
+	chartMinColumnGap = 120
+	chartJunctionGap  = 20 // distance from the end of the previous column to each junction
+	chartArrowSize    = 6
+)
+
+// chart is a laid-out breeding chart. Flowers are placed in columns by
+// generation, with each breeding drawn as lines from both parents meeting at a
+// junction, & an arrow from the junction to the child.
+type chart struct {
+	width, height int
+	nodes         []chartNode
+	edges         []chartEdge
+}
+
+type chartNode struct {
+	x, y, w, h int // bounding box; (x, y) is the top-left corner
+	label      string
+	fills      []chartFill
+	textColor  string
+	target     bool
+}
+
+// chartFill is a vertical band of a node's fill, covering the given fraction of
+// the node's width.
+type chartFill struct {
+	color    string
+	fraction float64
+}
+
+type chartEdge struct {
+	parents    [2]chartPoint // points on the right side of each parent
+	junction   chartPoint
+	child      chartPoint // point on the left side of the child
+	label      string
+	labelColor string
+}
+
+type chartPoint struct{ x, y int }
+
+func layoutChart(n namer, sg Subgraph) *chart {
+	is := index(sg)
+	var target breedgraph.Vertex
+	if p, ok := sg.(*PathSubgraph); ok {
+		target = p.Target()
+	}
+
+	// Determine each vertex's generation, from the edges in the subgraph.
+	predEdge := map[int]breedgraph.Edge{}
+	for _, e := range is.edges {
+		predEdge[is.ids[e.Child()]] = e
+	}
+	gens := make([]int, len(is.verts))
+	for i := range gens {
+		gens[i] = -1
+	}
+	var gen func(int) int
+	gen = func(id int) int {
+		if gens[id] >= 0 {
+			return gens[id]
+		}
+		gens[id] = 0 // guards against cycles
+		e, ok := predEdge[id]
+		if !ok {
+			return 0
+		}
+		g := gen(is.ids[e.FirstParent()])
+		if g2 := gen(is.ids[e.SecondParent()]); g2 > g {
+			g = g2
+		}
+		gens[id] = g + 1
+		return g + 1
+	}
+	var layers [][]int
+	for id := range is.verts {
+		g := gen(id)
+		for len(layers) <= g {
+			layers = append(layers, nil)
+		}
+		layers[g] = append(layers[g], id)
+	}
+
+	// Order each layer by the average position of each vertex's parents, to
+	// reduce crossings.
+	pos := make([]float64, len(is.verts))
+	for l, layer := range layers {
+		if l > 0 {
+			key := map[int]float64{}
+			for _, id := range layer {
+				e := predEdge[id]
+				key[id] = (pos[is.ids[e.FirstParent()]] + pos[is.ids[e.SecondParent()]]) / 2
+			}
+			sort.SliceStable(layer, func(i, j int) bool { return key[layer[i]] < key[layer[j]] })
+		}
+		for i, id := range layer {
+			pos[id] = float64(i)
+		}
+	}
+
+	// Create nodes, & determine column widths & positions.
+	c := &chart{nodes: make([]chartNode, len(is.verts))}
+	for id, v := range is.verts {
+		label := n.name(v.Value())
+		c.nodes[id] = chartNode{
+			w:      fontWidth*len([]rune(label)) + 2*chartNodePadX,
+			h:      chartNodeHeight,
+			label:  label,
+			target: v == target,
+		}
+		c.nodes[id].fills, c.nodes[id].textColor = nodeFills(n.s, v.Value())
+	}
+	edgeLabels := make([]string, len(is.edges))
+	columnGap := chartMinColumnGap
+	for i, e := range is.edges {
+		edgeLabels[i] = edgeLabel(e.Test().Name(), e.EdgeCost())
+		if w := chartJunctionGap + fontWidth*len([]rune(edgeLabels[i])) + 4*chartArrowSize; w > columnGap {
+			columnGap = w
+		}
+	}
+	columnX := make([]int, len(layers))
+	layerHeights := make([]int, len(layers))
+	x, maxLayerHeight := chartMargin, 0
+	for l, layer := range layers {
+		columnX[l] = x
+		width := 0
+		for _, id := range layer {
+			if w := c.nodes[id].w; w > width {
+				width = w
+			}
+		}
+		x += width + columnGap
+		layerHeights[l] = len(layer)*(chartNodeHeight+chartNodeGapY) - chartNodeGapY
+		if layerHeights[l] > maxLayerHeight {
+			maxLayerHeight = layerHeights[l]
+		}
+	}
+	c.width, c.height = x-columnGap+chartMargin, maxLayerHeight+2*chartMargin
+	for l, layer := range layers {
+		y := chartMargin + (maxLayerHeight-layerHeights[l])/2
+		for _, id := range layer {
+			c.nodes[id].x, c.nodes[id].y = columnX[l], y
+			y += chartNodeHeight + chartNodeGapY
+		}
+	}
+
+	// Create edges.
+	right := func(id int) chartPoint { nd := c.nodes[id]; return chartPoint{nd.x + nd.w, nd.y + nd.h/2} }
+	for i, e := range is.edges {
+		first, second, child := is.ids[e.FirstParent()], is.ids[e.SecondParent()], is.ids[e.Child()]
+		cn := c.nodes[child]
+		childPt := chartPoint{cn.x, cn.y + cn.h/2}
+		p0, p1 := right(first), right(second)
+		labelColor := "#000000"
+		if ps := sortedPhenotypes(n.s.PhenotypeDistribution(e.Child().Value())); len(ps) == 1 && !isLight(n.s.PhenotypeInfo(ps[0]).Color) {
+			labelColor = n.s.PhenotypeInfo(ps[0]).Color
+		}
+		c.edges = append(c.edges, chartEdge{
+			parents:    [2]chartPoint{p0, p1},
+			junction:   chartPoint{childPt.x - columnGap + chartJunctionGap, (p0.y + p1.y + 2*childPt.y) / 4},
+			child:      childPt,
+			label:      edgeLabels[i],
+			labelColor: labelColor,
+		})
+	}
+	return c
+}
+
+// nodeFills determines how to fill the node for gd: one band per possible
+// phenotype, in proportion to its odds. It also determines a readable text
+// color.
+func nodeFills(s flower.Species, gd flower.GeneticDistribution) ([]chartFill, string) {
+	pd := s.PhenotypeDistribution(gd)
+	var total uint64
+	for _, odds := range pd {
+		total += odds
+	}
+	var fills []chartFill
+	allDark := true
+	for _, p := range sortedPhenotypes(pd) {
+		color := s.PhenotypeInfo(p).Color
+		fills = append(fills, chartFill{color, float64(pd[p]) / float64(total)})
+		allDark = allDark && isDark(color)
+	}
+	if allDark {
+		return fills, "#ffffff"
+	}
+	return fills, "#000000"
+}
+
+// isLight determines if the given "#rrggbb" color is too light to be legible
+// on a white background.
+func isLight(color string) bool {
+	r, g, b := parseColor(color)
+	return 0.299*float64(r)+0.587*float64(g)+0.114*float64(b) > 170
+}
+
+func parseColor(color string) (r, g, b uint8) {
+	rgb, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb)
+}
+
+// svgRenderer renders subgraphs as SVG breeding charts.
+type svgRenderer struct{ namer }
+
+func (r svgRenderer) Render(w io.Writer, sg Subgraph) error {
+	c := layoutChart(r.namer, sg)
+	ew := &errWriter{w: w}
+	ew.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n", c.width, c.height, c.width, c.height)
+	ew.printf(`  <rect width="100%%" height="100%%" fill="#ffffff"/>` + "\n")
+
+	for _, e := range c.edges {
+		for _, p := range e.parents {
+			ew.printf(`  <polyline points="%d,%d %d,%d" fill="none" stroke="#555555"/>`+"\n", p.x, p.y, e.junction.x, e.junction.y)
+		}
+		ew.printf(`  <circle cx="%d" cy="%d" r="3" fill="#555555"/>`+"\n", e.junction.x, e.junction.y)
+		ew.printf(`  <line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#555555"/>`+"\n", e.junction.x, e.junction.y, e.child.x, e.child.y)
+		a, b := arrowhead(e.child)
+		ew.printf(`  <polygon points="%d,%d %d,%d %d,%d" fill="#555555"/>`+"\n", e.child.x, e.child.y, a.x, a.y, b.x, b.y)
+		lx, ly := (e.junction.x+e.child.x)/2, (e.junction.y+e.child.y)/2-4
+		ew.printf(`  <text x="%d" y="%d" text-anchor="middle" fill="%s">%s</text>`+"\n", lx, ly, e.labelColor, html.EscapeString(e.label))
+	}
+
+	for _, nd := range c.nodes {
+		x := float64(nd.x)
+		for _, f := range nd.fills {
+			w := f.fraction * float64(nd.w)
+			ew.printf(`  <rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`+"\n", x, nd.y, w, nd.h, f.color)
+			x += w
+		}
+		strokeWidth := 1
+		if nd.target {
+			strokeWidth = 3
+		}
+		ew.printf(`  <rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#000000" stroke-width="%d"/>`+"\n", nd.x, nd.y, nd.w, nd.h, strokeWidth)
+		ew.printf(`  <text x="%d" y="%d" text-anchor="middle" dominant-baseline="central" fill="%s">%s</text>`+"\n", nd.x+nd.w/2, nd.y+nd.h/2, nd.textColor, html.EscapeString(nd.label))
+	}
+	ew.printf("</svg>\n")
+	return ew.err
+}
+
+// arrowhead returns the two base corners of a rightward-pointing arrowhead with
+// its tip at the given point.
+func arrowhead(to chartPoint) (chartPoint, chartPoint) {
+	return chartPoint{to.x - chartArrowSize, to.y - chartArrowSize/2}, chartPoint{to.x - chartArrowSize, to.y + chartArrowSize/2}
+}
+
+// pngRenderer renders subgraphs as PNG breeding charts.
+type pngRenderer struct{ namer }
+
+func (r pngRenderer) Render(w io.Writer, sg Subgraph) error {
+	c := layoutChart(r.namer, sg)
+	img := image.NewRGBA(image.Rect(0, 0, c.width, c.height))
+	fillRect(img, img.Rect, toRGBA("#ffffff"))
+
+	lineColor := toRGBA("#555555")
+	for _, e := range c.edges {
+		for _, p := range e.parents {
+			drawLine(img, p, e.junction, lineColor)
+		}
+		fillRect(img, image.Rect(e.junction.x-2, e.junction.y-2, e.junction.x+3, e.junction.y+3), lineColor)
+		drawLine(img, e.junction, e.child, lineColor)
+		for i := 0; i < chartArrowSize; i++ {
+			drawLine(img, chartPoint{e.child.x - i, e.child.y - i/2}, chartPoint{e.child.x - i, e.child.y + i/2}, lineColor)
+		}
+		lx, ly := (e.junction.x+e.child.x)/2, (e.junction.y+e.child.y)/2-4
+		lw := fontWidth * len([]rune(e.label))
+		drawText(img, e.label, lx-lw/2, ly-fontHeight+2, toRGBA(e.labelColor))
+	}
+
+	black := toRGBA("#000000")
+	for _, nd := range c.nodes {
+		x := float64(nd.x)
+		for i, f := range nd.fills {
+			x1 := x + f.fraction*float64(nd.w)
+			if i == len(nd.fills)-1 {
+				x1 = float64(nd.x + nd.w)
+			}
+			fillRect(img, image.Rect(int(x+0.5), nd.y, int(x1+0.5), nd.y+nd.h), toRGBA(f.color))
+			x = x1
+		}
+		border := 1
+		if nd.target {
+			border = 3
+		}
+		for i := 0; i < border; i++ {
+			strokeRect(img, image.Rect(nd.x+i, nd.y+i, nd.x+nd.w-i, nd.y+nd.h-i), black)
+		}
+		drawText(img, nd.label, nd.x+chartNodePadX, nd.y+chartNodePadY, toRGBA(nd.textColor))
+	}
+
+	return png.Encode(w, img)
+}
+
+func toRGBA(c string) color.RGBA {
+	r, g, b := parseColor(c)
+	return color.RGBA{r, g, b, 0xff}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func strokeRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), c)
+	fillRect(img, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
+
+// drawLine draws a line using Bresenham's algorithm.
+func drawLine(img *image.RGBA, from, to chartPoint, c color.RGBA) {
+	abs := func(x int) int {
+		if x < 0 {
+			return -x
+		}
+		return x
+	}
+	dx, dy := abs(to.x-from.x), -abs(to.y-from.y)
+	sx, sy := 1, 1
+	if from.x > to.x {
+		sx = -1
+	}
+	if from.y > to.y {
+		sy = -1
+	}
+	x, y, e := from.x, from.y, dx+dy
+	for {
+		if (image.Point{x, y}).In(img.Rect) {
+			img.SetRGBA(x, y, c)
+		}
+		if x == to.x && y == to.y {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y += sy
+		}
+	}
+}
+
+// drawText draws text with its top-left corner at (x, y).
+func drawText(img *image.RGBA, text string, x, y int, c color.RGBA) {
+	for _, r := range text {
+		g := glyph(r)
+		for gy, row := range g {
+			for gx := 0; gx < fontWidth; gx++ {
+				if row&(1<<(fontWidth-1-gx)) != 0 {
+					if p := (image.Point{x + gx, y + gy}); p.In(img.Rect) {
+						img.SetRGBA(p.X, p.Y, c)
+					}
+				}
+			}
+		}
+		x += fontWidth
+	}
+}
@@ -0,0 +1,130 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BranLwyd/acnh_flowers/breedgraph"
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// dotRenderer renders subgraphs in Graphviz's DOT language, as a breeding
+// chart. Flowers are filled with their color (or colors, for flowers whose
+// color is not yet certain); flowers with a single possible genotype are drawn
+// as boxes, while others are drawn as ellipses. Starting flowers are grouped
+// together, & the target of a path is highlighted.
+type dotRenderer struct{ namer }
+
+func (r dotRenderer) Render(w io.Writer, sg Subgraph) error {
+	is := index(sg)
+	var target breedgraph.Vertex
+	if p, ok := sg.(*PathSubgraph); ok {
+		target = p.Target()
+	}
+
+	ew := &errWriter{w: w}
+	ew.printf("digraph {\n")
+	ew.printf("  rankdir=LR\n")
+	ew.printf("  label=%s\n", dotQuote(fmt.Sprintf("Plan chosen by the %s policy: %s.", sg.Policy().Name(), sg.Policy().Description())))
+	ew.printf("  labelloc=t\n")
+	ew.printf("  node [style=filled fontname=\"sans-serif\"]\n")
+	ew.printf("  edge [fontname=\"sans-serif\" fontsize=10]\n")
+
+	// Print vertices, with starting flowers clustered together.
+	ew.printf("\n  subgraph cluster_start {\n")
+	ew.printf("    label=\"Starting flowers\"\n")
+	ew.printf("    style=dashed\n")
+	for id, v := range is.verts {
+		if _, ok := v.BestPredecessor(); !ok {
+			ew.printf("    v%d [%s]\n", id, r.vertexAttrs(v, v == target))
+		}
+	}
+	ew.printf("  }\n\n")
+	for id, v := range is.verts {
+		if _, ok := v.BestPredecessor(); ok {
+			ew.printf("  v%d [%s]\n", id, r.vertexAttrs(v, v == target))
+		}
+	}
+
+	// Print edges. Each breeding is drawn as a point joining the two parents
+	// (drawn twice, if a flower is bred with another of its kind), with an
+	// arrow from that point to the child.
+	for i, e := range is.edges {
+		first, second, child := is.ids[e.FirstParent()], is.ids[e.SecondParent()], is.ids[e.Child()]
+		ew.printf("\n  e%d [shape=point width=0.08]\n", i)
+		ew.printf("  v%d -> e%d [arrowhead=none]\n", first, i)
+		ew.printf("  v%d -> e%d [arrowhead=none]\n", second, i)
+		ew.printf("  e%d -> v%d [%s]\n", i, child, r.edgeAttrs(e))
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+func (r dotRenderer) vertexAttrs(v breedgraph.Vertex, isTarget bool) string {
+	gd := v.Value()
+	pd := r.s.PhenotypeDistribution(gd)
+	ps := sortedPhenotypes(pd)
+
+	var genotypeCnt int
+	gd.Visit(func(flower.Genotype, uint64) bool {
+		genotypeCnt++
+		return true
+	})
+
+	attrs := []string{"label=" + dotQuote(r.name(gd))}
+	if genotypeCnt == 1 {
+		attrs = append(attrs, "shape=box")
+	} else {
+		attrs = append(attrs, "shape=ellipse")
+	}
+	if len(ps) == 1 {
+		color := r.s.PhenotypeInfo(ps[0]).Color
+		attrs = append(attrs, "fillcolor="+dotQuote(color))
+		if isDark(color) {
+			attrs = append(attrs, "fontcolor=white")
+		}
+	} else {
+		// Split the fill between possible colors, in proportion to their odds.
+		var total uint64
+		for _, odds := range pd {
+			total += odds
+		}
+		colors := make([]string, len(ps))
+		for i, p := range ps {
+			colors[i] = fmt.Sprintf("%s;%.3f", r.s.PhenotypeInfo(p).Color, float64(pd[p])/float64(total))
+		}
+		attrs = append(attrs, `style="wedged"`, "fillcolor="+dotQuote(strings.Join(colors, ":")))
+	}
+	if isTarget {
+		attrs = append(attrs, "penwidth=3", "peripheries=2")
+	}
+	return strings.Join(attrs, " ")
+}
+
+func (r dotRenderer) edgeAttrs(e breedgraph.Edge) string {
+	label := fmt.Sprintf("cost %.2f (p=%.3g%%)", e.EdgeCost(), 100/e.EdgeCost())
+	if name := e.Test().Name(); name != "" {
+		label = name + "\n" + label
+	}
+	attrs := []string{"label=" + dotQuote(label)}
+	if e.Test().Name() == "" {
+		// Untested breedings keep every child.
+		attrs = append(attrs, "style=dashed")
+	} else if ps := sortedPhenotypes(r.s.PhenotypeDistribution(e.Child().Value())); len(ps) == 1 {
+		attrs = append(attrs, "color="+dotQuote(r.s.PhenotypeInfo(ps[0]).Color), "penwidth=2")
+	}
+	return strings.Join(attrs, " ")
+}
+
+// isDark determines if the given "#rrggbb" color is dark enough to require
+// light text.
+func isDark(color string) bool {
+	r, g, b := parseColor(color)
+	return 0.299*float64(r)+0.587*float64(g)+0.114*float64(b) < 128
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotQuote returns s as a quoted DOT ID.
+func dotQuote(s string) string { return `"` + dotEscaper.Replace(s) + `"` }
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/BranLwyd/acnh_flowers/breedgraph"
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// guideRenderer renders subgraphs as numbered, human-readable instructions.
+// Edges are rendered as steps in the order they are visited, so this is most
+// useful for subgraphs returned by Path.
+type guideRenderer struct{ namer }
+
+func (r guideRenderer) Render(w io.Writer, sg Subgraph) error {
+	var starts []flower.GeneticDistribution
+	sg.VisitVertices(func(v breedgraph.Vertex) {
+		if _, ok := v.BestPredecessor(); !ok {
+			starts = append(starts, v.Value())
+		}
+	})
+	var edges []breedgraph.Edge
+	sg.VisitEdges(func(e breedgraph.Edge) {
+		edges = append(edges, e)
+	})
+
+	ew := &errWriter{w: w}
+	ew.printf("Plan chosen by the %s policy: %s.\n\n", sg.Policy().Name(), sg.Policy().Description())
+	if len(edges) == 0 {
+		names := make([]string, len(starts))
+		for i, gd := range starts {
+			names[i] = r.name(gd)
+		}
+		ew.printf("Already available: %s. No breeding is necessary.\n", strings.Join(names, ", "))
+		return ew.err
+	}
+
+	startNames := make([]string, len(starts))
+	for i, gd := range starts {
+		startNames[i] = r.name(gd)
+	}
+	ew.printf("Start with: %s.\n", strings.Join(startNames, ", "))
+
+	steps := map[flower.GeneticDistribution]int{}
+	describe := func(gd flower.GeneticDistribution) string {
+		if r.hasName(gd) {
+			return r.name(gd)
+		}
+		step, ok := steps[gd]
+		if !ok {
+			return r.name(gd)
+		}
+		return fmt.Sprintf("the %s flower from step %d", strings.Join(phenotypeNames(r.s, gd), "/"), step)
+	}
+
+	for i, e := range edges {
+		step := i + 1
+		first, second, child := e.FirstParent().Value(), e.SecondParent().Value(), e.Child().Value()
+		ew.printf("\n")
+		if first == second {
+			ew.printf("Step %d: Breed two of %s together.\n", step, describe(first))
+		} else {
+			ew.printf("Step %d: Breed %s with %s.\n", step, describe(first), describe(second))
+		}
+
+		// Describe the children that can result, & which should be kept.
+		pd := r.s.PhenotypeDistribution(first.Breed(second))
+		var total uint64
+		for _, odds := range pd {
+			total += odds
+		}
+		var outcomes []string
+		for _, p := range sortedPhenotypes(pd) {
+			outcomes = append(outcomes, fmt.Sprintf("%.1f%% %s", 100*float64(pd[p])/float64(total), r.s.RenderPhenotype(p)))
+		}
+		ew.printf("  Children will be %s.\n", strings.Join(outcomes, ", "))
+
+		kept := phenotypeNames(r.s, child)
+		if len(kept) == len(pd) {
+			ew.printf("  Keep any child.\n")
+		} else {
+			ew.printf("  Keep only %s children (expect 1 in %.3g).\n", joinOr(kept), e.EdgeCost())
+		}
+		ew.printf("  Result: %s\n", r.name(child))
+		steps[child] = step
+	}
+
+	if p, ok := sg.(*PathSubgraph); ok {
+		ew.printf("\nExpected total attempts: %.3g\n", p.Target().PathCost())
+	}
+	return ew.err
+}
+
+// phenotypeNames returns the names of the phenotypes possible in gd, in
+// phenotype order.
+func phenotypeNames(s flower.Species, gd flower.GeneticDistribution) []string {
+	var rslt []string
+	for _, p := range sortedPhenotypes(s.PhenotypeDistribution(gd)) {
+		rslt = append(rslt, s.RenderPhenotype(p))
+	}
+	return rslt
+}
+
+func sortedPhenotypes(pd map[flower.Phenotype]uint64) []flower.Phenotype {
+	rslt := make([]flower.Phenotype, 0, len(pd))
+	for p := range pd {
+		rslt = append(rslt, p)
+	}
+	sort.Slice(rslt, func(i, j int) bool { return rslt[i] < rslt[j] })
+	return rslt
+}
+
+// joinOr joins strs in a human-readable list, e.g. "A, B or C".
+func joinOr(strs []string) string {
+	if len(strs) <= 1 {
+		return strings.Join(strs, "")
+	}
+	return strings.Join(strs[:len(strs)-1], ", ") + " or " + strs[len(strs)-1]
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// jsonRenderer renders subgraphs as JSON, suitable for consumption by other
+// tools. The schema is described by the json* types below; fields will only
+// ever be added to it.
+type jsonRenderer struct{ namer }
+
+type jsonSubgraph struct {
+	Species  string       `json:"species"`
+	Policy   string       `json:"policy"`
+	Vertices []jsonVertex `json:"vertices"`
+	Edges    []jsonEdge   `json:"edges"`
+}
+
+type jsonVertex struct {
+	ID           int            `json:"id"`
+	Name         string         `json:"name,omitempty"`
+	Distribution string         `json:"distribution"`
+	Genotypes    []jsonGenotype `json:"genotypes"`
+	PathCost     float64        `json:"pathCost"`
+}
+
+type jsonGenotype struct {
+	Genotype  string `json:"genotype"`
+	Odds      uint64 `json:"odds"`
+	Phenotype string `json:"phenotype"`
+}
+
+type jsonEdge struct {
+	Parents  [2]int  `json:"parents"`
+	Child    int     `json:"child"`
+	Test     string  `json:"test"`
+	Cost     float64 `json:"cost"`
+	PathCost float64 `json:"pathCost"`
+}
+
+func (r jsonRenderer) Render(w io.Writer, sg Subgraph) error {
+	is := index(sg)
+	js := jsonSubgraph{
+		Species:  r.s.Name(),
+		Policy:   sg.Policy().Name(),
+		Vertices: make([]jsonVertex, 0, len(is.verts)),
+		Edges:    make([]jsonEdge, 0, len(is.edges)),
+	}
+	for id, v := range is.verts {
+		gd := v.Value()
+		jv := jsonVertex{
+			ID:           id,
+			Distribution: r.s.RenderGeneticDistribution(gd),
+			PathCost:     v.PathCost(),
+		}
+		if r.hasName(gd) {
+			jv.Name = r.name(gd)
+		}
+		gd.Visit(func(g flower.Genotype, odds uint64) bool {
+			jv.Genotypes = append(jv.Genotypes, jsonGenotype{r.s.RenderGenotype(g), odds, r.s.RenderPhenotype(r.s.Phenotype(g))})
+			return true
+		})
+		js.Vertices = append(js.Vertices, jv)
+	}
+	for _, e := range is.edges {
+		js.Edges = append(js.Edges, jsonEdge{
+			Parents:  [2]int{is.ids[e.FirstParent()], is.ids[e.SecondParent()]},
+			Child:    is.ids[e.Child()],
+			Test:     e.Test().Name(),
+			Cost:     e.EdgeCost(),
+			PathCost: e.PathCost(),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(js)
+}
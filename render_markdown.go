@@ -0,0 +1,36 @@
+package render
+
+import (
+	"io"
+	"strings"
+)
+
+// markdownRenderer renders subgraphs as Markdown tables.
+type markdownRenderer struct{ namer }
+
+func (r markdownRenderer) Render(w io.Writer, sg Subgraph) error {
+	is := index(sg)
+	ew := &errWriter{w: w}
+
+	ew.printf("Plan chosen by the %s policy: %s.\n\n", markdownEscape(sg.Policy().Name()), markdownEscape(sg.Policy().Description()))
+	ew.printf("## Flowers\n\n")
+	ew.printf("| Flower | Distribution | Colors | Path cost |\n")
+	ew.printf("| --- | --- | --- | ---: |\n")
+	for _, v := range is.verts {
+		gd := v.Value()
+		ew.printf("| %s | %s | %s | %.2f |\n", markdownEscape(r.name(gd)), markdownEscape(r.s.RenderGeneticDistribution(gd)), strings.Join(phenotypeNames(r.s, gd), ", "), v.PathCost())
+	}
+
+	ew.printf("\n## Breeding\n\n")
+	ew.printf("| Step | First parent | Second parent | Test | Cost | Child |\n")
+	ew.printf("| ---: | --- | --- | --- | ---: | --- |\n")
+	for i, e := range is.edges {
+		ew.printf("| %d | %s | %s | %s | %.2f | %s |\n", i+1, markdownEscape(r.name(e.FirstParent().Value())), markdownEscape(r.name(e.SecondParent().Value())), markdownEscape(e.Test().Name()), e.EdgeCost(), markdownEscape(r.name(e.Child().Value())))
+	}
+	return ew.err
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "\n", " ")
+
+// markdownEscape escapes s for inclusion in a Markdown table cell.
+func markdownEscape(s string) string { return markdownEscaper.Replace(s) }
@@ -0,0 +1,33 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mermaidRenderer renders subgraphs as Mermaid flowcharts.
+type mermaidRenderer struct{ namer }
+
+func (r mermaidRenderer) Render(w io.Writer, sg Subgraph) error {
+	is := index(sg)
+	ew := &errWriter{w: w}
+	ew.printf("flowchart LR\n")
+	for id, v := range is.verts {
+		ew.printf("  v%d[%s]\n", id, mermaidQuote(r.name(v.Value())))
+	}
+	for _, e := range is.edges {
+		first, second, child := is.ids[e.FirstParent()], is.ids[e.SecondParent()], is.ids[e.Child()]
+		parents := fmt.Sprintf("v%d & v%d", first, second)
+		if first == second {
+			parents = fmt.Sprintf("v%d", first)
+		}
+		ew.printf("  %s -->|%s| v%d\n", parents, mermaidQuote(edgeLabel(e.Test().Name(), e.EdgeCost())), child)
+	}
+	return ew.err
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "\n", "<br>")
+
+// mermaidQuote returns s as a quoted Mermaid string.
+func mermaidQuote(s string) string { return `"` + mermaidEscaper.Replace(s) + `"` }
@@ -0,0 +1,163 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/BranLwyd/acnh_flowers/breedgraph"
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// testPath returns the path to an orange rose, bred from the red & yellow seeds.
+func testPath(t *testing.T) (flower.Species, *PathSubgraph, map[flower.GeneticDistribution]string) {
+	t.Helper()
+	s := flower.Roses()
+	red, err := s.ParseGeneticDistribution("RRyyWWSs")
+	if err != nil {
+		t.Fatalf("Couldn't parse genetic distribution: %v", err)
+	}
+	yellow, err := s.ParseGeneticDistribution("rrYYWWss")
+	if err != nil {
+		t.Fatalf("Couldn't parse genetic distribution: %v", err)
+	}
+	orange, err := s.ParseGeneticDistribution("RrYyWWss")
+	if err != nil {
+		t.Fatalf("Couldn't parse genetic distribution: %v", err)
+	}
+
+	g := breedgraph.NewGraph([]*breedgraph.Test{breedgraph.PhenotypeTest(s, flower.Orange)}, []flower.GeneticDistribution{red, yellow})
+	g.Expand(func(flower.GeneticDistribution) bool { return true })
+	v, ok := g.Search(func(gd flower.GeneticDistribution) bool { return gd == orange })
+	if !ok {
+		t.Fatalf("Couldn't find orange rose")
+	}
+	names := map[flower.GeneticDistribution]string{
+		red:    `Seed "Red"`,
+		yellow: `Seed \Yellow|`,
+	}
+	return s, Path(g, v), names
+}
+
+func TestRenderEscaping(t *testing.T) {
+	s, p, names := testPath(t)
+	for _, test := range []struct {
+		format string
+		want   []string
+	}{
+		{"dot", []string{`label="Seed \"Red\""`, `label="Seed \\Yellow|"`}},
+		{"mermaid", []string{`v0["Seed #quot;Red#quot;"]`, `v1["Seed \Yellow|"]`}},
+		{"markdown", []string{`| Seed "Red" | Seed \\Yellow\| |`}},
+		{"svg", []string{`>Seed &#34;Red&#34;</text>`, `>P∈{Orange} (2.00)</text>`}},
+	} {
+		t.Run(test.format, func(t *testing.T) {
+			r, err := New(test.format, s, names)
+			if err != nil {
+				t.Fatalf("New got unexpected error: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := r.Render(&buf, p); err != nil {
+				t.Fatalf("Render got unexpected error: %v", err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Render output does not contain %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRenderPolicy(t *testing.T) {
+	s, p, names := testPath(t)
+	for _, test := range []struct {
+		format string
+		want   string
+	}{
+		{"dot", `label="Plan chosen by the min-cost policy: lowest expected number of breedings, then simplest final test."`},
+		{"guide", "Plan chosen by the min-cost policy: lowest expected number of breedings, then simplest final test.\n"},
+		{"markdown", "Plan chosen by the min-cost policy: lowest expected number of breedings, then simplest final test.\n"},
+	} {
+		t.Run(test.format, func(t *testing.T) {
+			r, err := New(test.format, s, names)
+			if err != nil {
+				t.Fatalf("New got unexpected error: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := r.Render(&buf, p); err != nil {
+				t.Fatalf("Render got unexpected error: %v", err)
+			}
+			if !strings.Contains(buf.String(), test.want) {
+				t.Errorf("Render output does not contain %q:\n%s", test.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	s, p, names := testPath(t)
+	r, err := New("json", s, names)
+	if err != nil {
+		t.Fatalf("New got unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, p); err != nil {
+		t.Fatalf("Render got unexpected error: %v", err)
+	}
+
+	var got jsonSubgraph
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Couldn't unmarshal rendered JSON: %v", err)
+	}
+	if len(got.Vertices) != 3 || len(got.Edges) != 1 {
+		t.Fatalf("Rendered JSON has %d vertices & %d edges, want 3 & 1", len(got.Vertices), len(got.Edges))
+	}
+	if got.Policy != "min-cost" {
+		t.Errorf("Rendered JSON has policy %q, want %q", got.Policy, "min-cost")
+	}
+	e := got.Edges[0]
+	if got.Vertices[e.Child].Distribution != "{1:RrYyWWss}" || got.Vertices[e.Child].Genotypes[0].Phenotype != "Orange" {
+		t.Errorf("Rendered JSON has unexpected child vertex %+v", got.Vertices[e.Child])
+	}
+	if e.Cost != 2 || e.Test != "P∈{Orange}" {
+		t.Errorf("Rendered JSON has unexpected edge %+v", e)
+	}
+	for _, id := range e.Parents {
+		if got.Vertices[id].Name == "" {
+			t.Errorf("Rendered JSON has unnamed parent vertex %+v", got.Vertices[id])
+		}
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	s, p, names := testPath(t)
+	r, err := New("png", s, names)
+	if err != nil {
+		t.Fatalf("New got unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, p); err != nil {
+		t.Fatalf("Render got unexpected error: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Couldn't decode rendered PNG: %v", err)
+	}
+	c := layoutChart(namer{s, names}, p)
+	if b := img.Bounds(); b.Dx() != c.width || b.Dy() != c.height {
+		t.Errorf("Rendered PNG has size %dx%d, want %dx%d", b.Dx(), b.Dy(), c.width, c.height)
+	}
+	// The child's node should be filled with the color of an orange rose.
+	for _, nd := range c.nodes {
+		if nd.label != "{1:RrYyWWss}" {
+			continue
+		}
+		want := toRGBA(flower.Orange.Color())
+		if r, g, b, _ := img.At(nd.x+4, nd.y+4).RGBA(); uint8(r>>8) != want.R || uint8(g>>8) != want.G || uint8(b>>8) != want.B {
+			t.Errorf("Rendered PNG has child node color (%d, %d, %d), want %v", r>>8, g>>8, b>>8, want)
+		}
+	}
+}
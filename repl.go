@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BranLwyd/acnh_flowers/breedgraph"
+	"github.com/BranLwyd/acnh_flowers/flower"
+	"github.com/BranLwyd/acnh_flowers/render"
+)
+
+const (
+	replPlanSteps = 3 // default number of expansion steps used by the REPL's plan command
+)
+
+// replCommand runs an interactive read-eval-print loop for exploring flower
+// genetics.
+func replCommand(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	speciesName := fs.String("species", "Roses", "The species of flower to start with.")
+	fs.Parse(args)
+
+	s, ok := flower.SpeciesByName(*speciesName)
+	if !ok {
+		return fmt.Errorf("unknown species %q", *speciesName)
+	}
+	prompt := ""
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		prompt = "> "
+		fmt.Printf("Exploring %s. Type \"help\" for a list of commands.\n", s.Name())
+	}
+	return newREPL(s, os.Stdout).run(os.Stdin, prompt)
+}
+
+// repl holds the state of a read-eval-print loop: the current species, named
+// variables, & the history of results.
+type repl struct {
+	w       io.Writer
+	s       flower.Species
+	vars    map[string]replValue
+	history []replValue
+}
+
+// replValue is a genetic distribution, along with the species it belongs to.
+type replValue struct {
+	s  flower.Species
+	gd flower.GeneticDistribution
+}
+
+// replCmd is a command understood by the REPL. If run returns a value, it is
+// recorded in the history & may be assigned to a variable.
+type replCmd struct {
+	usage, help string
+	run         func(r *repl, args []string) (_ replValue, ok bool, _ error)
+}
+
+var replCmds map[string]replCmd
+
+func init() {
+	// Initialized in init to avoid an initialization loop via the help command.
+	replCmds = map[string]replCmd{
+		"breed":   {"breed <a> <b>", "Breed two flowers, giving the distribution of their children.", (*repl).breed},
+		"filter":  {"filter <a> <phenotype>...", "Restrict a distribution to genotypes having one of the given phenotypes.", (*repl).filter},
+		"help":    {"help", "Show this help.", (*repl).help},
+		"history": {"history", "List previous results.", (*repl).listHistory},
+		"plan":    {"plan <target genotype> [steps]", "Plan how to breed a genotype from seeds & variables.", (*repl).plan},
+		"show":    {"show [a]", "Show the genotypes & phenotypes of a distribution (by default, the last result).", (*repl).show},
+		"species": {"species [name]", "Show or change the current species. Changing species keeps existing variables.", (*repl).species},
+		"vars":    {"vars", "List variables.", (*repl).listVars},
+	}
+}
+
+func newREPL(s flower.Species, w io.Writer) *repl {
+	return &repl{w: w, s: s, vars: map[string]replValue{}}
+}
+
+// run reads & evaluates lines from in until EOF or a "quit" command. Errors
+// evaluating individual lines are reported rather than returned.
+func (r *repl) run(in io.Reader, prompt string) error {
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.w, prompt)
+		if !sc.Scan() {
+			break
+		}
+		line := strings.TrimSpace(sc.Text())
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+		if err := r.eval(line); err != nil {
+			fmt.Fprintf(r.w, "Error: %v\n", err)
+		}
+	}
+	if prompt != "" {
+		fmt.Fprintln(r.w)
+	}
+	return sc.Err()
+}
+
+var (
+	replVarRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	replOddsRe = regexp.MustCompile(`^(?:\d+(?:\.\d+)?%|\d+/\d+)$`)
+)
+
+// eval evaluates a single line, which is either a command, a bare value, or
+// an assignment of either to a variable, e.g. "x = breed RRyyWWSs rrYYWWss".
+func (r *repl) eval(line string) error {
+	toks, err := tokenize(line)
+	if err != nil {
+		return err
+	}
+	if len(toks) == 0 {
+		return nil
+	}
+
+	varName := ""
+	if len(toks) >= 2 && toks[1] == "=" {
+		varName, toks = toks[0], toks[2:]
+		if !replVarRe.MatchString(varName) {
+			return fmt.Errorf("invalid variable name %q", varName)
+		}
+		if _, ok := replCmds[varName]; ok {
+			return fmt.Errorf("invalid variable name %q: it is the name of a command", varName)
+		}
+		if len(toks) == 0 {
+			return errors.New("missing value to assign")
+		}
+	}
+
+	var val replValue
+	if cmd, ok := replCmds[toks[0]]; ok {
+		v, ok, err := cmd.run(r, toks[1:])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if varName != "" {
+				return fmt.Errorf("%s does not produce a value", toks[0])
+			}
+			return nil
+		}
+		val = v
+	} else {
+		if len(toks) != 1 {
+			return fmt.Errorf("unknown command %q", toks[0])
+		}
+		v, err := r.value(toks[0])
+		if err != nil {
+			return err
+		}
+		val = v
+	}
+
+	r.history = append(r.history, val)
+	ref := fmt.Sprintf("$%d", len(r.history))
+	if varName != "" {
+		r.vars[varName] = val
+		ref = fmt.Sprintf("%s = %s", varName, ref)
+	}
+	fmt.Fprintf(r.w, "%s = %s\n", ref, val.s.RenderGeneticDistribution(val.gd))
+	fmt.Fprintf(r.w, "  %s\n", phenotypeSummary(val.s, val.gd))
+	return nil
+}
+
+// tokenize splits a line into whitespace-separated tokens. Genetic
+// distributions are kept as a single token even if they contain spaces: those
+// wrapped in braces, genes with alternatives wrapped in parentheses, genotypes
+// followed by odds written as a percentage or fraction (e.g. "RrYy??ss 50%"),
+// & shorthands such as "Orange from seeds". "=" is always a token of its own.
+func tokenize(line string) ([]string, error) {
+	var rslt []string
+	var tok strings.Builder
+	depth := 0
+	flush := func() {
+		if tok.Len() > 0 {
+			rslt = append(rslt, tok.String())
+			tok.Reset()
+		}
+	}
+	for _, c := range line {
+		switch {
+		case c == '{' || c == '(':
+			depth++
+			tok.WriteRune(c)
+		case c == '}' || c == ')':
+			if depth == 0 {
+				return nil, errors.New("unbalanced braces or parentheses")
+			}
+			depth--
+			tok.WriteRune(c)
+		case depth > 0:
+			tok.WriteRune(c)
+		case c == '=':
+			flush()
+			rslt = append(rslt, "=")
+		case c == ' ' || c == '\t':
+			flush()
+		default:
+			tok.WriteRune(c)
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced braces or parentheses")
+	}
+	flush()
+	return joinDistributionTokens(rslt), nil
+}
+
+// joinDistributionTokens joins tokens which together form a single genetic
+// distribution: a genotype & its odds, or a "from seeds" shorthand. Odds must
+// be written as a percentage or fraction, so as not to be confused with other
+// numeric arguments.
+func joinDistributionTokens(toks []string) []string {
+	var rslt []string
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if i+1 < len(toks) && strings.EqualFold(toks[i+1], "from") && tok != "=" {
+			for j := i + 2; j < len(toks); j++ {
+				if strings.EqualFold(toks[j], "seeds") {
+					tok, i = strings.Join(toks[i:j+1], " "), j
+					break
+				}
+			}
+		}
+		if n := len(rslt); n > 0 && rslt[n-1] != "=" && replOddsRe.MatchString(tok) {
+			rslt[n-1] += " " + tok
+			continue
+		}
+		rslt = append(rslt, tok)
+	}
+	return rslt
+}
+
+// value resolves a reference to a value: "$" for the last result, "$N" for the
+// Nth result, a variable name, or a genotype or genetic distribution of the
+// current species.
+func (r *repl) value(ref string) (replValue, error) {
+	var val replValue
+	switch {
+	case ref == "$":
+		if len(r.history) == 0 {
+			return replValue{}, errors.New("no previous result")
+		}
+		val = r.history[len(r.history)-1]
+
+	case strings.HasPrefix(ref, "$"):
+		n, err := strconv.Atoi(ref[1:])
+		if err != nil || n < 1 || n > len(r.history) {
+			return replValue{}, fmt.Errorf("no such result %q", ref)
+		}
+		val = r.history[n-1]
+
+	default:
+		if v, ok := r.vars[ref]; ok {
+			val = v
+			break
+		}
+		gd, err := r.s.ParseGeneticDistribution(ref)
+		if err != nil {
+			if replVarRe.MatchString(ref) {
+				return replValue{}, fmt.Errorf("%q is neither a variable nor a genotype of %s", ref, r.s.Name())
+			}
+			return replValue{}, err
+		}
+		val = replValue{r.s, gd}
+	}
+
+	if val.s.Name() != r.s.Name() {
+		return replValue{}, fmt.Errorf("%s is a flower of species %s, but the current species is %s", ref, val.s.Name(), r.s.Name())
+	}
+	return val, nil
+}
+
+func (r *repl) breed(args []string) (replValue, bool, error) {
+	if len(args) != 2 {
+		return replValue{}, false, errors.New("usage: " + replCmds["breed"].usage)
+	}
+	a, err := r.value(args[0])
+	if err != nil {
+		return replValue{}, false, err
+	}
+	b, err := r.value(args[1])
+	if err != nil {
+		return replValue{}, false, err
+	}
+	return replValue{r.s, a.gd.Breed(b.gd)}, true, nil
+}
+
+func (r *repl) filter(args []string) (replValue, bool, error) {
+	if len(args) < 2 {
+		return replValue{}, false, errors.New("usage: " + replCmds["filter"].usage)
+	}
+	a, err := r.value(args[0])
+	if err != nil {
+		return replValue{}, false, err
+	}
+	keep := map[flower.Phenotype]bool{}
+	for _, arg := range args[1:] {
+		p, err := r.s.ParsePhenotype(arg)
+		if err != nil {
+			return replValue{}, false, err
+		}
+		keep[p] = true
+	}
+	gd := a.gd.Update(func(mgd *flower.MutableGeneticDistribution) {
+		a.gd.Visit(func(g flower.Genotype, _ uint64) bool {
+			if !keep[r.s.Phenotype(g)] {
+				mgd.SetOdds(g, 0)
+			}
+			return true
+		})
+	})
+	if gd.IsZero() {
+		return replValue{}, false, fmt.Errorf("%s can't have phenotype %s", args[0], strings.Join(args[1:], " or "))
+	}
+	return replValue{r.s, gd}, true, nil
+}
+
+func (r *repl) help(args []string) (replValue, bool, error) {
+	var names []string
+	for name := range replCmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(r.w, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", replCmds[name].usage, replCmds[name].help)
+	}
+	fmt.Fprintf(tw, "  quit\tExit.\n")
+	tw.Flush()
+	fmt.Fprintf(r.w, "Values may be genotypes (RrYyWWss), genetic distributions ({1:RrYyWWss, 1:RRYyWWss}),\n")
+	fmt.Fprintf(r.w, "variables, $ (the last result) or $N (the Nth result). Assign results with \"name = ...\".\n")
+	return replValue{}, false, nil
+}
+
+func (r *repl) listHistory(args []string) (replValue, bool, error) {
+	for i, val := range r.history {
+		fmt.Fprintf(r.w, "$%d = %s [%s]\n", i+1, val.s.RenderGeneticDistribution(val.gd), val.s.Name())
+	}
+	return replValue{}, false, nil
+}
+
+func (r *repl) listVars(args []string) (replValue, bool, error) {
+	var names []string
+	for name := range r.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		val := r.vars[name]
+		fmt.Fprintf(r.w, "%s = %s [%s]\n", name, val.s.RenderGeneticDistribution(val.gd), val.s.Name())
+	}
+	return replValue{}, false, nil
+}
+
+func (r *repl) plan(args []string) (replValue, bool, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return replValue{}, false, errors.New("usage: " + replCmds["plan"].usage)
+	}
+	target, err := r.s.ParseGenotype(args[0])
+	if err != nil {
+		return replValue{}, false, err
+	}
+	steps := replPlanSteps
+	if len(args) == 2 {
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+			return replValue{}, false, fmt.Errorf("invalid step count %q", args[1])
+		}
+	}
+
+	// Plan from the seeds, along with any flowers of this species held in
+	// variables.
+	names := seedNames(r.s)
+	var initial []flower.GeneticDistribution
+	for _, g := range r.s.Seeds() {
+		initial = append(initial, g.ToGeneticDistribution())
+	}
+	var varNames []string
+	for name := range r.vars {
+		varNames = append(varNames, name)
+	}
+	sort.Strings(varNames)
+	for _, name := range varNames {
+		val := r.vars[name]
+		if val.s.Name() != r.s.Name() {
+			continue
+		}
+		if _, ok := names[val.gd]; !ok {
+			initial = append(initial, val.gd)
+		}
+		names[val.gd] = name
+	}
+
+	v, g, err := plan(r.s, initial, target, planOptions{steps, true, breedgraph.MinCostPolicy, nil})
+	if err != nil {
+		return replValue{}, false, err
+	}
+	rr, err := render.New("guide", r.s, names)
+	if err != nil {
+		return replValue{}, false, err
+	}
+	if err := rr.Render(r.w, render.Path(g, v)); err != nil {
+		return replValue{}, false, err
+	}
+	return replValue{r.s, v.Value()}, true, nil
+}
+
+func (r *repl) show(args []string) (replValue, bool, error) {
+	ref := "$"
+	switch len(args) {
+	case 0:
+	case 1:
+		ref = args[0]
+	default:
+		return replValue{}, false, errors.New("usage: " + replCmds["show"].usage)
+	}
+	val, err := r.value(ref)
+	if err != nil {
+		return replValue{}, false, err
+	}
+	return replValue{}, false, printDistribution(r.w, val.s, val.gd)
+}
+
+func (r *repl) species(args []string) (replValue, bool, error) {
+	switch len(args) {
+	case 0:
+		var names []string
+		for _, s := range flower.AllSpecies() {
+			names = append(names, s.Name())
+		}
+		fmt.Fprintf(r.w, "Current species: %s (available: %s)\n", r.s.Name(), strings.Join(names, ", "))
+	case 1:
+		s, ok := flower.SpeciesByName(args[0])
+		if !ok {
+			return replValue{}, false, fmt.Errorf("unknown species %q", args[0])
+		}
+		r.s = s
+		fmt.Fprintf(r.w, "Current species: %s\n", r.s.Name())
+	default:
+		return replValue{}, false, errors.New("usage: " + replCmds["species"].usage)
+	}
+	return replValue{}, false, nil
+}
+
+// printDistribution prints each genotype of gd with its probability &
+// phenotype, followed by the probability of each phenotype.
+func printDistribution(w io.Writer, s flower.Species, gd flower.GeneticDistribution) error {
+	var total uint64
+	gd.Visit(func(_ flower.Genotype, odds uint64) bool {
+		total += odds
+		return true
+	})
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Genotype\tProbability\tPhenotype\n")
+	gd.Visit(func(g flower.Genotype, odds uint64) bool {
+		fmt.Fprintf(tw, "%s\t%.2f%%\t%s\n", s.RenderGenotype(g), 100*float64(odds)/float64(total), s.RenderPhenotype(s.Phenotype(g)))
+		return true
+	})
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nPhenotypes: %s\n", phenotypeSummary(s, gd))
+	return err
+}
+
+// phenotypeSummary describes the probability of each phenotype in gd, e.g.
+// "50.00% Red, 50.00% Orange".
+func phenotypeSummary(s flower.Species, gd flower.GeneticDistribution) string {
+	pd := s.PhenotypeDistribution(gd)
+	var total uint64
+	var ps []flower.Phenotype
+	for p, odds := range pd {
+		total += odds
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	var parts []string
+	for _, p := range ps {
+		parts = append(parts, fmt.Sprintf("%.2f%% %s", 100*float64(pd[p])/float64(total), s.RenderPhenotype(p)))
+	}
+	return strings.Join(parts, ", ")
+}
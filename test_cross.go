@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// testCrossCommand ranks tester flowers by how well they identify the genotype
+// of a flower whose genotype is only known to follow some distribution.
+func testCrossCommand(args []string) error {
+	fs := flag.NewFlagSet("test-cross", flag.ExitOnError)
+	speciesName := fs.String("species", "Roses", "The species of flower to test.")
+	confidence := fs.Float64("confidence", 0.95, "The desired probability of correctly identifying the flower's genotype.")
+	var testerStrs stringsFlag
+	fs.Var(&testerStrs, "tester", "A genetic distribution of a flower available for test crosses. May be repeated. If unspecified, the species' seed flowers are used.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s test-cross [flags] <genetic distribution>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *confidence <= 0 || *confidence >= 1 {
+		return fmt.Errorf("confidence must be strictly between 0 & 1")
+	}
+
+	s, ok := flower.SpeciesByName(*speciesName)
+	if !ok {
+		return fmt.Errorf("unknown species %q", *speciesName)
+	}
+	unknown, err := s.ParseGeneticDistribution(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	names := seedNames(s)
+	var testers []flower.GeneticDistribution
+	if len(testerStrs) == 0 {
+		for _, g := range s.Seeds() {
+			testers = append(testers, g.ToGeneticDistribution())
+		}
+	}
+	for _, str := range testerStrs {
+		gd, err := s.ParseGeneticDistribution(str)
+		if err != nil {
+			return fmt.Errorf("couldn't parse tester: %v", err)
+		}
+		testers = append(testers, gd)
+	}
+
+	fmt.Printf("Unknown flower: %s\n\n", s.RenderGeneticDistribution(unknown))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Tester\tInformation gain\tOffspring for %g%% confidence\n", 100**confidence)
+	for _, tc := range s.RankTestCrosses(unknown, testers, *confidence) {
+		name, ok := names[tc.Tester]
+		if !ok {
+			name = s.RenderGeneticDistribution(tc.Tester)
+		}
+		offspring := "unreachable"
+		if tc.Offspring >= 0 {
+			offspring = fmt.Sprintf("%d", tc.Offspring)
+		}
+		fmt.Fprintf(tw, "%s\t%.3f bits\t%s\n", name, tc.InformationGain, offspring)
+	}
+	return tw.Flush()
+}
+
+// stringsFlag is a flag.Value collecting each occurrence of a repeatable flag.
+type stringsFlag []string
+
+func (sf *stringsFlag) String() string { return strings.Join(*sf, ", ") }
+
+func (sf *stringsFlag) Set(v string) error {
+	*sf = append(*sf, v)
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/BranLwyd/acnh_flowers/flower"
+)
+
+// verifySpeciesCommand compares a user-supplied species table (in the CSV
+// format understood by flower.ReadSpeciesTable) against the built-in species,
+// reporting any mismatches.
+func verifySpeciesCommand(args []string) error {
+	fs := flag.NewFlagSet("verify-species", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s verify-species <table.csv>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("couldn't open species table: %v", err)
+	}
+	defer f.Close()
+	entries, err := flower.ReadSpeciesTable(f)
+	if err != nil {
+		return err
+	}
+
+	ms := flower.VerifySpeciesTable(entries)
+	for _, m := range ms {
+		fmt.Println(m)
+	}
+	if len(ms) != 0 {
+		return fmt.Errorf("found %d mismatch(es)", len(ms))
+	}
+	fmt.Fprintf(os.Stderr, "All %d entries match the built-in species.\n", len(entries))
+	return nil
+}